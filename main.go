@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -23,6 +26,12 @@ import (
 
 const appDescription = "Service serving requests made towards suggestions umbrella"
 const suggestPath = "/content/suggest"
+const suggestByUUIDPath = "/content/{uuid}/suggest"
+const suggestDiffPath = "/content/suggest/diff"
+const suggestDiffByUUIDPath = "/content/{uuid}/suggest/diff"
+const bulkPathPrefix = "/bulk"
+const adminBlacklistPath = "/__admin/blacklist"
+const adminBlacklistEntryPath = "/__admin/blacklist/{uuid}"
 
 func main() {
 	app := cli.App("public-suggestions-api", appDescription)
@@ -115,6 +124,311 @@ func main() {
 		EnvVar: "CONCEPT_BLACKLISTER_ENDPOINT",
 	})
 
+	contentAPIBaseURL := app.String(cli.StringOpt{
+		Name:   "content-api-base-url",
+		Value:  "http://content-public-read:8080",
+		Desc:   "The base URL for the content api used to retrieve existing content",
+		EnvVar: "CONTENT_API_BASE_URL",
+	})
+	contentEndpoint := app.String(cli.StringOpt{
+		Name:   "content-endpoint",
+		Value:  "/content",
+		Desc:   "The endpoint for the content api",
+		EnvVar: "CONTENT_ENDPOINT",
+	})
+	annotationsAPIBaseURL := app.String(cli.StringOpt{
+		Name:   "annotations-api-base-url",
+		Value:  "http://public-annotations-api:8080",
+		Desc:   "The base URL for the annotations api used to retrieve existing annotations",
+		EnvVar: "ANNOTATIONS_API_BASE_URL",
+	})
+	annotationsEndpoint := app.String(cli.StringOpt{
+		Name:   "annotations-endpoint",
+		Value:  "/content/{uuid}/annotations",
+		Desc:   "The endpoint for the annotations api",
+		EnvVar: "ANNOTATIONS_ENDPOINT",
+	})
+	deepHealthchecksEnabled := app.Bool(cli.BoolOpt{
+		Name:   "deep-healthchecks-enabled",
+		Value:  false,
+		Desc:   "Periodically probe the real endpoints of the downstream services and report the results in the health checks",
+		EnvVar: "DEEP_HEALTHCHECKS_ENABLED",
+	})
+	deepHealthchecksInterval := app.Int(cli.IntOpt{
+		Name:   "deep-healthchecks-interval-seconds",
+		Value:  60,
+		Desc:   "The interval in seconds between two deep health check probes",
+		EnvVar: "DEEP_HEALTHCHECKS_INTERVAL_SECONDS",
+	})
+	deepHealthchecksConceptUUID := app.String(cli.StringOpt{
+		Name:   "deep-healthchecks-concept-uuid",
+		Value:  service.HealthcheckConceptUUID,
+		Desc:   "The UUID of a known concept used by the deep health checks",
+		EnvVar: "DEEP_HEALTHCHECKS_CONCEPT_UUID",
+	})
+	criticalDependencies := app.Strings(cli.StringsOpt{
+		Name:   "critical-dependencies",
+		Value:  []string{"internal-concordances"},
+		Desc:   "The IDs of the health checks of the downstream services without which suggestions can't work. The good-to-go fails when any of them has been unhealthy for the whole unhealthy window",
+		EnvVar: "CRITICAL_DEPENDENCIES",
+	})
+	criticalUnhealthyWindow := app.Int(cli.IntOpt{
+		Name:   "critical-unhealthy-window-seconds",
+		Value:  120,
+		Desc:   "The time in seconds a critical dependency has to be unhealthy before the good-to-go fails",
+		EnvVar: "CRITICAL_UNHEALTHY_WINDOW_SECONDS",
+	})
+	suggestionsCacheSize := app.Int(cli.IntOpt{
+		Name:   "suggestions-cache-size",
+		Value:  1000,
+		Desc:   "The maximum number of payloads whose suggestions are cached. 0 disables the cache",
+		EnvVar: "SUGGESTIONS_CACHE_SIZE",
+	})
+	suggestionsCacheTTL := app.Int(cli.IntOpt{
+		Name:   "suggestions-cache-ttl-seconds",
+		Value:  60,
+		Desc:   "The time in seconds the suggestions for a payload are cached",
+		EnvVar: "SUGGESTIONS_CACHE_TTL_SECONDS",
+	})
+	mergeSuggestions := app.Bool(cli.BoolOpt{
+		Name:   "merge-suggestions",
+		Value:  false,
+		Desc:   "Collapse the suggestions of the same concept made by different suggesters into one, listing all the suggesters as its sources, for all the requests rather than only those with merge=true",
+		EnvVar: "MERGE_SUGGESTIONS",
+	})
+	predicatePriority := app.Strings(cli.StringsOpt{
+		Name: "predicate-priority",
+		Value: []string{
+			"http://www.ft.com/ontology/annotation/hasAuthor",
+			"http://www.ft.com/ontology/annotation/about",
+			"http://www.ft.com/ontology/annotation/mentions",
+		},
+		Desc:   "The predicates kept when merged suggestions disagree, highest priority first",
+		EnvVar: "PREDICATE_PRIORITY",
+	})
+	broaderPolicy := app.String(cli.StringOpt{
+		Name:   "broader-policy",
+		Value:  string(service.BroaderPolicyExclude),
+		Desc:   "What happens to suggestions broader than other suggestions: exclude, annotate (keep with a broaderOf list) or demote (annotate and move to the end)",
+		EnvVar: "BROADER_POLICY",
+	})
+	broaderTypePolicies := app.Strings(cli.StringsOpt{
+		Name:   "broader-type-policies",
+		Value:  []string{},
+		Desc:   "Broader policies for specific concept types, as type=policy, overriding the broader policy",
+		EnvVar: "BROADER_TYPE_POLICIES",
+	})
+	broaderDepth := app.String(cli.StringOpt{
+		Name:   "broader-depth",
+		Value:  string(service.BroaderDepthTransitive),
+		Desc:   "Whether only the direct broader concepts of the suggestions are considered, or all their ancestors: direct or transitive",
+		EnvVar: "BROADER_DEPTH",
+	})
+	impliedConceptTypes := app.Strings(cli.StringsOpt{
+		Name:   "implied-concept-types",
+		Value:  []string{},
+		Desc:   "The types of the suggestions whose broader concepts are added as implied suggestions when requested with include=implied. No types disables implied suggestions",
+		EnvVar: "IMPLIED_CONCEPT_TYPES",
+	})
+	impliedConceptDepth := app.Int(cli.IntOpt{
+		Name:   "implied-concept-depth",
+		Value:  1,
+		Desc:   "The number of broader levels implied above a suggestion",
+		EnvVar: "IMPLIED_CONCEPT_DEPTH",
+	})
+	includeDeprecatedConcepts := app.Bool(cli.BoolOpt{
+		Name:   "include-deprecated-concepts",
+		Value:  false,
+		Desc:   "Keep the suggestions of deprecated concepts, flagged with isDeprecated, instead of dropping them",
+		EnvVar: "INCLUDE_DEPRECATED_CONCEPTS",
+	})
+	concordanceFailureMode := app.String(cli.StringOpt{
+		Name:   "concordance-failure-mode",
+		Value:  string(service.ConcordanceFailureModeFail),
+		Desc:   "What happens when internal concordances fails: fail the request, return the suggestions unconcorded, or concord them from the cache of previous lookups (fail, unconcorded or cache)",
+		EnvVar: "CONCORDANCE_FAILURE_MODE",
+	})
+	concordanceCacheSize := app.Int(cli.IntOpt{
+		Name:   "concordance-cache-size",
+		Value:  10000,
+		Desc:   "The maximum number of concorded concepts cached for the cache concordance failure mode",
+		EnvVar: "CONCORDANCE_CACHE_SIZE",
+	})
+	concordanceCacheTTL := app.Int(cli.IntOpt{
+		Name:   "concordance-cache-ttl-seconds",
+		Value:  86400,
+		Desc:   "The time in seconds the concorded concepts are cached for the cache concordance failure mode",
+		EnvVar: "CONCORDANCE_CACHE_TTL_SECONDS",
+	})
+	adminAPIKey := app.String(cli.StringOpt{
+		Name:   "admin-api-key",
+		Value:  "",
+		Desc:   "The API key of the admin endpoints managing the local blacklist overlay. No key disables the admin endpoints and the overlay",
+		EnvVar: "ADMIN_API_KEY",
+	})
+	blacklistOverlayFile := app.String(cli.StringOpt{
+		Name:   "blacklist-overlay-file",
+		Value:  "",
+		Desc:   "The file persisting the local blacklist overlay. No file keeps the overlay in memory only",
+		EnvVar: "BLACKLIST_OVERLAY_FILE",
+	})
+	suggestionRulesFile := app.String(cli.StringOpt{
+		Name:   "suggestion-rules-file",
+		Value:  "",
+		Desc:   "The JSON file of rules suggesting concepts whenever their keywords or patterns appear in the content. No file disables the rules",
+		EnvVar: "SUGGESTION_RULES_FILE",
+	})
+	gazetteerFile := app.String(cli.StringOpt{
+		Name:   "gazetteer-file",
+		Value:  "",
+		Desc:   "The JSON snapshot of concepts, with their aliases, recognised locally in the content. No file disables the gazetteer",
+		EnvVar: "GAZETTEER_FILE",
+	})
+	gazetteerMode := app.String(cli.StringOpt{
+		Name:   "gazetteer-mode",
+		Value:  "fallback",
+		Desc:   "How the gazetteer is used: fallback (replaces Ontotext when it fails) or additional (another suggestion source)",
+		EnvVar: "GAZETTEER_MODE",
+	})
+	staffListFile := app.String(cli.StringOpt{
+		Name:   "staff-list-file",
+		Value:  "",
+		Desc:   "The JSON snapshot of FT authors, with their aliases, matched against the parsed byline to cross-check the authors suggestion API and replace it when it fails. No file disables byline parsing",
+		EnvVar: "STAFF_LIST_FILE",
+	})
+	suggesterFallbacks := app.Strings(cli.StringsOpt{
+		Name:   "suggester-fallbacks",
+		Value:  []string{},
+		Desc:   "Chains of suggesters per concept type, as type=primary|secondary, promoting the suggestions of the type made by the secondary suggester when the primary one fails or has no content, e.g. author=Authors Suggestion API|Ontotext Suggestion API",
+		EnvVar: "SUGGESTER_FALLBACKS",
+	})
+	ontotextHedgingPercentile := app.Int(cli.IntOpt{
+		Name:   "ontotext-hedging-percentile",
+		Value:  0,
+		Desc:   "The percentile of the recent Ontotext latencies after which a second identical request is sent, the first answer winning. 0 disables hedging",
+		EnvVar: "ONTOTEXT_HEDGING_PERCENTILE",
+	})
+	ontotextHedgingMinDelay := app.Int(cli.IntOpt{
+		Name:   "ontotext-hedging-min-delay-ms",
+		Value:  100,
+		Desc:   "The minimum time in milliseconds before an Ontotext request is hedged",
+		EnvVar: "ONTOTEXT_HEDGING_MIN_DELAY_MS",
+	})
+	ontotextHedgingBaseURL := app.String(cli.StringOpt{
+		Name:   "ontotext-hedging-base-url",
+		Value:  "",
+		Desc:   "The base URL the hedged Ontotext requests are sent to. Defaults to the Ontotext suggestion API base URL",
+		EnvVar: "ONTOTEXT_HEDGING_BASE_URL",
+	})
+	balancingPolicy := app.String(cli.StringOpt{
+		Name:   "balancing-policy",
+		Value:  string(service.BalancingPolicyRoundRobin),
+		Desc:   "How the requests are spread over the endpoints of a service with several comma separated base URLs: round-robin or least-outstanding",
+		EnvVar: "BALANCING_POLICY",
+	})
+	endpointEjectionFailures := app.Int(cli.IntOpt{
+		Name:   "endpoint-ejection-failures",
+		Value:  5,
+		Desc:   "The number of consecutive failures ejecting an endpoint of a service with several base URLs. 0 disables ejection",
+		EnvVar: "ENDPOINT_EJECTION_FAILURES",
+	})
+	endpointEjectionDuration := app.Int(cli.IntOpt{
+		Name:   "endpoint-ejection-seconds",
+		Value:  30,
+		Desc:   "The time in seconds an ejected endpoint is left out",
+		EnvVar: "ENDPOINT_EJECTION_SECONDS",
+	})
+	maxInFlightRequests := app.Int(cli.IntOpt{
+		Name:   "max-in-flight-requests",
+		Value:  0,
+		Desc:   "The number of suggestion requests handled at once, the others waiting in a queue or being shed. 0 disables the limit",
+		EnvVar: "MAX_IN_FLIGHT_REQUESTS",
+	})
+	adaptiveConcurrencyLimit := app.Bool(cli.BoolOpt{
+		Name:   "adaptive-concurrency-limit",
+		Value:  false,
+		Desc:   "Whether the limit of suggestion requests handled at once is lowered while their latency rises, back up to max-in-flight-requests as it recovers",
+		EnvVar: "ADAPTIVE_CONCURRENCY_LIMIT",
+	})
+	requestQueueSize := app.Int(cli.IntOpt{
+		Name:   "request-queue-size",
+		Value:  100,
+		Desc:   "The number of suggestion requests waiting for their turn over the limit, the next ones being shed with a 429",
+		EnvVar: "REQUEST_QUEUE_SIZE",
+	})
+	requestQueueTimeout := app.Int(cli.IntOpt{
+		Name:   "request-queue-timeout-ms",
+		Value:  1000,
+		Desc:   "The time in milliseconds a suggestion request waits for its turn before being shed with a 503",
+		EnvVar: "REQUEST_QUEUE_TIMEOUT_MS",
+	})
+	shedRetryAfter := app.Int(cli.IntOpt{
+		Name:   "shed-retry-after-seconds",
+		Value:  1,
+		Desc:   "The Retry-After sent with the suggestion requests shed by the concurrency limit or the aggregation lanes",
+		EnvVar: "SHED_RETRY_AFTER_SECONDS",
+	})
+	downstreamConcurrency := app.Strings(cli.StringsOpt{
+		Name:   "downstream-concurrency",
+		Value:  []string{},
+		Desc:   "Caps of the requests in progress per downstream service, as service=cap, e.g. ontotext-suggestion-api=50. The services are authors-suggestion-api, ontotext-suggestion-api, public-things-api, internal-concordances, concept-suggestions-blacklister, content-api and public-annotations-api",
+		EnvVar: "DOWNSTREAM_CONCURRENCY",
+	})
+	clientLimitsFile := app.String(cli.StringOpt{
+		Name:   "client-limits-file",
+		Value:  "",
+		Desc:   "The JSON configuration of the rate limits and daily quotas of the clients of the suggestion endpoints, identified by their API key or X-Client-Id. No file disables them",
+		EnvVar: "CLIENT_LIMITS_FILE",
+	})
+	aggregationCapacity := app.Int(cli.IntOpt{
+		Name:   "aggregation-capacity",
+		Value:  0,
+		Desc:   "The number of suggestion aggregations run at once, shared between the interactive and bulk lanes. 0 disables the lanes",
+		EnvVar: "AGGREGATION_CAPACITY",
+	})
+	bulkAggregationCapacity := app.Int(cli.IntOpt{
+		Name:   "bulk-aggregation-capacity",
+		Value:  0,
+		Desc:   "The part of the aggregation capacity the bulk lane can use when the interactive lane leaves it spare. 0 defaults to half of it",
+		EnvVar: "BULK_AGGREGATION_CAPACITY",
+	})
+	interactiveLaneMaxWait := app.Int(cli.IntOpt{
+		Name:   "interactive-lane-max-wait-ms",
+		Value:  5000,
+		Desc:   "The time in milliseconds an interactive request waits for its turn to be aggregated before failing",
+		EnvVar: "INTERACTIVE_LANE_MAX_WAIT_MS",
+	})
+	bulkLaneMaxWait := app.Int(cli.IntOpt{
+		Name:   "bulk-lane-max-wait-ms",
+		Value:  60000,
+		Desc:   "The time in milliseconds a bulk request waits for its turn to be aggregated before failing",
+		EnvVar: "BULK_LANE_MAX_WAIT_MS",
+	})
+	maxPayloadBytes := app.Int(cli.IntOpt{
+		Name:   "max-payload-bytes",
+		Value:  5 << 20,
+		Desc:   "The size in bytes of the biggest payload accepted by the suggestion endpoints, the bigger ones being rejected with a 413. 0 accepts payloads of any size",
+		EnvVar: "MAX_PAYLOAD_BYTES",
+	})
+	bodyChunkBytes := app.Int(cli.IntOpt{
+		Name:   "body-chunk-bytes",
+		Value:  0,
+		Desc:   "The size in bytes of the cleaned body above which it is split into chunks of at most that size, sent to the suggesters separately. 0 disables the splitting",
+		EnvVar: "BODY_CHUNK_BYTES",
+	})
+	maxBodyChunks := app.Int(cli.IntOpt{
+		Name:   "max-body-chunks",
+		Value:  20,
+		Desc:   "The number of chunks past which a split body is truncated. 0 keeps all of it",
+		EnvVar: "MAX_BODY_CHUNKS",
+	})
+	bodyChunkConcurrency := app.Int(cli.IntOpt{
+		Name:   "body-chunk-concurrency",
+		Value:  4,
+		Desc:   "The number of chunks of a split body sent to a suggester at once. 0 sends them all at once",
+		EnvVar: "BODY_CHUNK_CONCURRENCY",
+	})
+
 	log := logger.NewUPPLogger(*appSystemCode, *logLevel)
 	app.Action = func() {
 		log.Infof("App Name: %s, Port: %s", *appName, *port)
@@ -130,16 +444,214 @@ func main() {
 			Timeout: 10 * time.Second,
 		}
 
-		authorsSuggester := service.NewAuthorsSuggester(*authorsSuggestionApiBaseURL, *authorsSuggestionEndpoint, c)
-		ontotextSuggester := service.NewOntotextSuggester(*ontotextSuggestionApiBaseURL, *ontotextSuggestionEndpoint, c)
-		broaderService := service.NewBroaderConceptsProvider(*publicThingsAPIBaseURL, *publicThingsEndpoint, c)
+		balancing, err := service.ParseBalancingPolicy(*balancingPolicy)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid balancing policy")
+		}
+		concurrencyCaps, err := parseDownstreamConcurrency(*downstreamConcurrency)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid downstream concurrency")
+		}
+		// downstream returns the base URL and client of a service, balancing its requests when it has several comma separated base URLs
+		// and capping the requests in progress when it has a cap, along with the uncapped client for the health checks,
+		// so that a service saturated by the suggestion requests isn't reported unhealthy
+		downstream := func(systemId string, baseURLs string) (string, service.Client, service.Client) {
+			baseURL, client := baseURLs, service.Client(c)
+			if strings.Contains(baseURLs, ",") {
+				balancer, err := service.NewBalancingClient(systemId, strings.Split(baseURLs, ","), balancing, c)
+				if err != nil {
+					log.WithError(err).Fatalf("Invalid %v base URLs", systemId)
+				}
+				balancer.EjectionFailures = *endpointEjectionFailures
+				balancer.EjectionDuration = time.Duration(*endpointEjectionDuration) * time.Second
+				baseURL, client = balancer.BaseURL(), balancer
+			}
+			if limit, ok := concurrencyCaps[systemId]; ok {
+				limited := service.NewLimitedClient(systemId, limit, client)
+				limited.MaxWait = c.Timeout
+				delete(concurrencyCaps, systemId)
+				return baseURL, limited, client
+			}
+			return baseURL, client, client
+		}
 
-		concordanceService := service.NewConcordance(*internalConcordancesApiBaseURL, *internalConcordancesEndpoint, c)
-		blacklister := service.NewConceptBlacklister(*conceptBlacklisterBaseUrl, *conceptBlacklisterEndpoint, c)
-		suggester := service.NewAggregateSuggester(log, concordanceService, broaderService, blacklister, authorsSuggester, ontotextSuggester)
-		healthService := web.NewHealthService(*appSystemCode, *appName, appDescription, authorsSuggester.Check(), ontotextSuggester.Check(), concordanceService.Check(), broaderService.Check(), blacklister.Check())
+		authorsURL, authorsClient, authorsHealthClient := downstream("authors-suggestion-api", *authorsSuggestionApiBaseURL)
+		authorsSuggester := service.NewAuthorsSuggester(authorsURL, *authorsSuggestionEndpoint, authorsClient)
+		ontotextURL, ontotextClient, ontotextHealthClient := downstream("ontotext-suggestion-api", *ontotextSuggestionApiBaseURL)
+		ontotextSuggester := service.NewOntotextSuggester(ontotextURL, *ontotextSuggestionEndpoint, ontotextClient)
+		if *ontotextHedgingPercentile > 0 {
+			if *ontotextHedgingPercentile >= 100 {
+				log.Fatalf("Invalid Ontotext hedging percentile %v", *ontotextHedgingPercentile)
+			}
+			ontotextSuggester.Hedging = service.NewHedging("ontotext-suggestion-api", float64(*ontotextHedgingPercentile)/100,
+				time.Duration(*ontotextHedgingMinDelay)*time.Millisecond, *ontotextHedgingBaseURL)
+		}
+		publicThingsURL, publicThingsClient, publicThingsHealthClient := downstream("public-things-api", *publicThingsAPIBaseURL)
+		broaderService := service.NewBroaderConceptsProvider(publicThingsURL, *publicThingsEndpoint, publicThingsClient)
+		policy, err := newBroaderConceptsPolicy(*broaderPolicy, *broaderTypePolicies, *broaderDepth)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid broader concepts policy")
+		}
+		broaderService.Policy = policy
+		broaderService.Implied = service.ImpliedConceptsPolicy{Types: *impliedConceptTypes, Depth: *impliedConceptDepth}
 
-		serveEndpoints(*port, web.NewRequestHandler(suggester, log), healthService, log)
+		concordancesURL, concordancesClient, concordancesHealthClient := downstream("internal-concordances", *internalConcordancesApiBaseURL)
+		concordanceService := service.NewConcordance(concordancesURL, *internalConcordancesEndpoint, concordancesClient)
+		concordanceService.IncludeDeprecated = *includeDeprecatedConcepts
+		blacklisterURL, blacklisterClient, blacklisterHealthClient := downstream("concept-suggestions-blacklister", *conceptBlacklisterBaseUrl)
+		blacklister := service.NewConceptBlacklister(blacklisterURL, *conceptBlacklisterEndpoint, blacklisterClient)
+		var adminHandler *web.AdminHandler
+		var overlay *service.BlacklistOverlay
+		if *adminAPIKey != "" {
+			overlay, err = service.NewBlacklistOverlay(*blacklistOverlayFile)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid blacklist overlay file")
+			}
+			blacklister = service.NewOverlayBlacklister(blacklister, overlay)
+			adminHandler = web.NewAdminHandler(overlay, *adminAPIKey, log)
+		}
+		contentURL, contentClient, contentHealthClient := downstream("content-api", *contentAPIBaseURL)
+		contentRetriever := service.NewContentRetriever(contentURL, *contentEndpoint, contentClient)
+		annotationsURL, annotationsClient, annotationsHealthClient := downstream("public-annotations-api", *annotationsAPIBaseURL)
+		annotationsRetriever := service.NewAnnotationsRetriever(annotationsURL, *annotationsEndpoint, annotationsClient)
+		var authors service.Suggester = authorsSuggester
+		if *staffListFile != "" {
+			staff, err := service.LoadGazetteer(*staffListFile)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid staff list file")
+			}
+			bylineAuthors, err := service.NewBylineAuthorsSuggester(staff, log)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid staff list")
+			}
+			authors = service.NewFallbackSuggester(bylineAuthors.CrossChecked(authorsSuggester), bylineAuthors)
+		}
+		suggesters := []service.Suggester{authors, ontotextSuggester}
+		if *gazetteerFile != "" {
+			entries, err := service.LoadGazetteer(*gazetteerFile)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid gazetteer file")
+			}
+			gazetteer, err := service.NewGazetteerSuggester(entries)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid gazetteer")
+			}
+			switch *gazetteerMode {
+			case "fallback":
+				suggesters = []service.Suggester{authors, service.NewFallbackSuggester(ontotextSuggester, gazetteer)}
+			case "additional":
+				suggesters = append(suggesters, gazetteer)
+			default:
+				log.Fatalf("Invalid gazetteer mode %v", *gazetteerMode)
+			}
+		}
+		if *suggestionRulesFile != "" {
+			rules, err := service.LoadSuggestionRules(*suggestionRulesFile)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid suggestion rules file")
+			}
+			rulesSuggester, err := service.NewRulesSuggester(rules)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid suggestion rules")
+			}
+			suggesters = append([]service.Suggester{rulesSuggester}, suggesters...)
+		}
+		suggester := service.NewAggregateSuggester(log, concordanceService, broaderService, blacklister, suggesters...)
+		suggester.Fallbacks, err = newSuggesterFallbacks(*suggesterFallbacks, suggesters)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid suggester fallbacks")
+		}
+		if *suggestionsCacheSize > 0 {
+			suggester.Cache = service.NewSuggestionsCache(*suggestionsCacheSize, time.Duration(*suggestionsCacheTTL)*time.Second)
+			if overlay != nil {
+				// the vetoes of the overlay are urgent, so they don't wait for the cached suggestions to expire
+				overlay.OnChange(suggester.Cache.Purge)
+			}
+		}
+		suggester.ConcordanceFailureMode, err = service.ParseConcordanceFailureMode(*concordanceFailureMode)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid concordance failure mode")
+		}
+		if suggester.ConcordanceFailureMode == service.ConcordanceFailureModeCache {
+			concordanceService.Cache = service.NewConceptsCache(*concordanceCacheSize, time.Duration(*concordanceCacheTTL)*time.Second)
+		}
+		suggester.Merger = service.NewSuggestionsMerger(*predicatePriority)
+		suggester.MergeOptIn = !*mergeSuggestions
+		if *bodyChunkBytes > 0 {
+			suggester.Segmentation = service.NewSegmentation(*bodyChunkBytes, *maxBodyChunks)
+			suggester.Segmentation.Concurrency = *bodyChunkConcurrency
+		}
+		if *aggregationCapacity > 0 {
+			bulkCapacity := *bulkAggregationCapacity
+			if bulkCapacity <= 0 {
+				bulkCapacity = (*aggregationCapacity + 1) / 2
+			}
+			suggester.Lanes = service.NewLanes(*aggregationCapacity, bulkCapacity)
+			suggester.Lanes.InteractiveMaxWait = time.Duration(*interactiveLaneMaxWait) * time.Millisecond
+			suggester.Lanes.BulkMaxWait = time.Duration(*bulkLaneMaxWait) * time.Millisecond
+		}
+
+		// the health checks and the probes of the deep checks bypass the downstream concurrency caps
+		authorsHealth := service.NewAuthorsSuggester(authorsURL, *authorsSuggestionEndpoint, authorsHealthClient)
+		ontotextHealth := service.NewOntotextSuggester(ontotextURL, *ontotextSuggestionEndpoint, ontotextHealthClient)
+		concordanceHealth := service.NewConcordance(concordancesURL, *internalConcordancesEndpoint, concordancesHealthClient)
+		concordanceHealth.IncludeDeprecated = concordanceService.IncludeDeprecated
+		broaderHealth := service.NewBroaderConceptsProvider(publicThingsURL, *publicThingsEndpoint, publicThingsHealthClient)
+		blacklisterHealth := service.NewConceptBlacklister(blacklisterURL, *conceptBlacklisterEndpoint, blacklisterHealthClient)
+
+		service.HealthcheckConceptUUID = *deepHealthchecksConceptUUID
+		healthService := web.NewHealthService(*appSystemCode, *appName, appDescription,
+			authorsHealth.Check(),
+			ontotextHealth.Check(),
+			concordanceHealth.Check(),
+			broaderHealth.Check(),
+			blacklisterHealth.Check(),
+			service.NewContentRetriever(contentURL, *contentEndpoint, contentHealthClient).Check(),
+			service.NewAnnotationsRetriever(annotationsURL, *annotationsEndpoint, annotationsHealthClient).Check(),
+		)
+		if *deepHealthchecksEnabled {
+			// the deep checks are only reported in the health, the good-to-go stays based on the shallow checks
+			var deepChecks []fthealth.Check
+			for _, target := range []interface{ Check() fthealth.Check }{authorsHealth, ontotextHealth, concordanceHealth, broaderHealth, blacklisterHealth} {
+				if deepCheck, ok := service.NewDeepHealthCheckOf(target, time.Duration(*deepHealthchecksInterval)*time.Second); ok {
+					deepCheck.Start()
+					deepChecks = append(deepChecks, deepCheck.Check())
+				}
+			}
+			healthService.UseDeepChecks(deepChecks...)
+		}
+		err = healthService.SetCriticalChecks(time.Duration(*criticalUnhealthyWindow)*time.Second, *criticalDependencies...)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid critical dependencies")
+		}
+
+		for systemId := range concurrencyCaps {
+			log.Fatalf("Invalid downstream concurrency of unknown service %v", systemId)
+		}
+
+		var limiter *web.ConcurrencyLimiter
+		if *maxInFlightRequests > 0 {
+			limiter = web.NewConcurrencyLimiter(*maxInFlightRequests, log)
+			limiter.Adaptive = *adaptiveConcurrencyLimit
+			limiter.QueueSize = *requestQueueSize
+			limiter.QueueTimeout = time.Duration(*requestQueueTimeout) * time.Millisecond
+			limiter.RetryAfter = time.Duration(*shedRetryAfter) * time.Second
+		}
+
+		var rateLimiter *web.RateLimiter
+		if *clientLimitsFile != "" {
+			limits, err := web.LoadClientLimits(*clientLimitsFile)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid client limits file")
+			}
+			rateLimiter = web.NewRateLimiter(limits, log)
+		}
+
+		handler := web.NewRequestHandler(suggester, contentRetriever, annotationsRetriever, log)
+		handler.MaxPayloadBytes = int64(*maxPayloadBytes)
+		handler.ShedRetryAfter = time.Duration(*shedRetryAfter) * time.Second
+
+		serveEndpoints(*port, handler, adminHandler, rateLimiter, limiter, healthService, log)
 
 	}
 	err := app.Run(os.Args)
@@ -149,7 +661,56 @@ func main() {
 	}
 }
 
-func serveEndpoints(port string, handler *web.RequestHandler, healthService *web.HealthService, log *logger.UPPLogger) {
+func newBroaderConceptsPolicy(defaultPolicy string, typePolicies []string, depth string) (service.BroaderConceptsPolicy, error) {
+	var policy service.BroaderConceptsPolicy
+	var err error
+	if policy.Default, err = service.ParseBroaderPolicy(defaultPolicy); err != nil {
+		return policy, err
+	}
+	if policy.ByType, err = service.ParseBroaderTypePolicies(typePolicies); err != nil {
+		return policy, err
+	}
+	policy.Depth, err = service.ParseBroaderDepth(depth)
+	return policy, err
+}
+
+func newSuggesterFallbacks(values []string, suggesters []service.Suggester) ([]service.SuggesterFallback, error) {
+	fallbacks, err := service.ParseSuggesterFallbacks(values)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, suggester := range suggesters {
+		names[suggester.GetName()] = true
+	}
+	for _, fallback := range fallbacks {
+		for _, name := range fallback.Suggesters {
+			if !names[name] {
+				return nil, fmt.Errorf("unknown suggester %q in the %v fallback", name, fallback.Type)
+			}
+		}
+	}
+	return fallbacks, nil
+}
+
+// parseDownstreamConcurrency parses the service=cap values of the downstream concurrency caps.
+func parseDownstreamConcurrency(values []string) (map[string]int, error) {
+	caps := make(map[string]int, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid downstream concurrency %q, expected service=cap", value)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid downstream concurrency %q, expected a positive cap", value)
+		}
+		caps[strings.TrimSpace(parts[0])] = limit
+	}
+	return caps, nil
+}
+
+func serveEndpoints(port string, handler *web.RequestHandler, adminHandler *web.AdminHandler, rateLimiter *web.RateLimiter, limiter *web.ConcurrencyLimiter, healthService *web.HealthService, log *logger.UPPLogger) {
 
 	serveMux := http.NewServeMux()
 
@@ -158,7 +719,31 @@ func serveEndpoints(port string, handler *web.RequestHandler, healthService *web
 	serveMux.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
 
 	servicesRouter := mux.NewRouter()
-	servicesRouter.HandleFunc(suggestPath, handler.HandleSuggestion).Methods(http.MethodPost)
+	// limited rejects the requests of the clients over their limits before they take a turn of the concurrency limit
+	limited := func(handler *web.RequestHandler, next http.HandlerFunc) http.HandlerFunc {
+		if limiter != nil {
+			next = handler.LimitInteractive(limiter, next)
+		}
+		if rateLimiter != nil {
+			next = rateLimiter.Limit(next)
+		}
+		return next
+	}
+	// the suggestion endpoints are also served under the bulk prefix, putting the requests in the bulk lane by default
+	for _, lane := range []struct {
+		prefix  string
+		handler *web.RequestHandler
+	}{{"", handler}, {bulkPathPrefix, handler.WithLane(service.LaneBulk)}} {
+		servicesRouter.HandleFunc(lane.prefix+suggestPath, limited(lane.handler, lane.handler.HandleSuggestion)).Methods(http.MethodPost)
+		servicesRouter.HandleFunc(lane.prefix+suggestByUUIDPath, limited(lane.handler, lane.handler.HandleSuggestionByUUID)).Methods(http.MethodGet)
+		servicesRouter.HandleFunc(lane.prefix+suggestDiffPath, limited(lane.handler, lane.handler.HandleSuggestionDiff)).Methods(http.MethodPost)
+		servicesRouter.HandleFunc(lane.prefix+suggestDiffByUUIDPath, limited(lane.handler, lane.handler.HandleSuggestionDiffByUUID)).Methods(http.MethodGet)
+	}
+	if adminHandler != nil {
+		servicesRouter.HandleFunc(adminBlacklistPath, adminHandler.Authenticate(adminHandler.HandleListBlacklist)).Methods(http.MethodGet)
+		servicesRouter.HandleFunc(adminBlacklistPath, adminHandler.Authenticate(adminHandler.HandleAddToBlacklist)).Methods(http.MethodPost)
+		servicesRouter.HandleFunc(adminBlacklistEntryPath, adminHandler.Authenticate(adminHandler.HandleRemoveFromBlacklist)).Methods(http.MethodDelete)
+	}
 
 	var monitoringRouter http.Handler = servicesRouter
 	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, monitoringRouter)
@@ -119,12 +119,14 @@ func TestRequestHandler_all(t *testing.T) {
 	}
 	tests := []struct {
 		testName            string
+		method              string
 		url                 string
 		expectedStatus      int
 		expectedSuggestions []service.Suggestion
 	}{
 		{
 			testName:       "okSuggestions",
+			method:         http.MethodPost,
 			url:            "http://localhost:8081/content/suggest",
 			expectedStatus: http.StatusOK,
 			expectedSuggestions: []service.Suggestion{
@@ -135,6 +137,25 @@ func TestRequestHandler_all(t *testing.T) {
 				expectedOntotextSuggestions[1],
 			},
 		},
+		{
+			testName:       "okSuggestionsByUUID",
+			method:         http.MethodGet,
+			url:            "http://localhost:8081/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest",
+			expectedStatus: http.StatusOK,
+			expectedSuggestions: []service.Suggestion{
+				expectedOntotextSuggestions[2],
+				expectedOntotextSuggestions[0],
+				expectedOntotextSuggestions[3],
+				expectedAuthorsSuggestions[0],
+				expectedOntotextSuggestions[1],
+			},
+		},
+		{
+			testName:       "invalidUUID",
+			method:         http.MethodGet,
+			url:            "http://localhost:8081/content/not-a-uuid/suggest",
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -229,6 +250,14 @@ func TestRequestHandler_all(t *testing.T) {
 			_, _ = w.Write([]byte(`{
 					"uuids": []
 				}`))
+		case strings.HasSuffix(r.RequestURI, "/annotations"):
+			_, _ = w.Write([]byte(`[]`))
+		case strings.Contains(r.RequestURI, "/content/"):
+			_, _ = w.Write([]byte(`{
+					"id": "http://www.ft.com/thing/9d5e441e-0b02-11e8-8eb7-42f857ea9f09",
+					"title": "test",
+					"bodyXML": "<body>test</body>"
+				}`))
 		}
 	}))
 
@@ -250,18 +279,21 @@ func TestRequestHandler_all(t *testing.T) {
 	concordance := service.NewConcordance(mockServer.URL, "/internalconcordances", c)
 	broaderProvider := service.NewBroaderConceptsProvider(mockServer.URL, "/things", c)
 	blacklister := service.NewConceptBlacklister(mockServer.URL, "/blacklist", c)
+	contentRetriever := service.NewContentRetriever(mockServer.URL, "/content", c)
+	annotationsRetriever := service.NewAnnotationsRetriever(mockServer.URL, "/content/{uuid}/annotations", c)
 
 	suggester := service.NewAggregateSuggester(log, concordance, broaderProvider, blacklister, authorsSuggester, ontotextSuggester)
 	healthService := web.NewHealthService("mock", "mock", "", authorsSuggester.Check(), ontotextSuggester.Check(), broaderProvider.Check())
 
 	go func() {
-		serveEndpoints("8081", web.NewRequestHandler(suggester, log), healthService, log)
+		serveEndpoints("8081", web.NewRequestHandler(suggester, contentRetriever, annotationsRetriever, log), nil, nil, nil, healthService, log)
 	}()
+	waitForServer(t, "localhost:8081")
 	client := &http.Client{}
 
 	for _, test := range tests {
 
-		req, _ := http.NewRequest("POST", test.url, strings.NewReader(`{"body":"test"}`))
+		req, _ := http.NewRequest(test.method, test.url, strings.NewReader(`{"body":"test"}`))
 		res, err := client.Do(req)
 		assert.NoErrorf(t, err, "%s -> unexpected error", test.testName)
 
@@ -282,3 +314,15 @@ func TestRequestHandler_all(t *testing.T) {
 	}
 
 }
+
+func waitForServer(t *testing.T, addr string) {
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("server at %s did not start", addr)
+}
@@ -1,21 +1,77 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	fp "path/filepath"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
 )
 
+var vetoReasonRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
 const PanicGuideURL = "https://runbooks.in.ft.com/"
 
+// The reasons the suggestions are dropped for, counted in the suggestions.dropped.<reason> metrics.
+const (
+	dropReasonUnconcorded   = "unconcorded"
+	dropReasonUntrustedType = "untrusted-type"
+	dropReasonBroader       = "broader"
+	dropReasonBlacklisted   = "blacklisted"
+)
+
 type AggregateSuggester struct {
 	Concordance     *ConcordanceService
 	BroaderProvider *BroaderConceptsProvider
 	Blacklister     ConceptBlacklister
 	Suggesters      []Suggester
-	Log             *logger.UPPLogger
+	Cache           *SuggestionsCache
+	Merger          *SuggestionsMerger
+	// MergeOptIn only merges the suggestions of the requests asking for it, instead of all of them.
+	MergeOptIn bool
+	// Fallbacks are the chains of suggesters promoting the suggestions of a type when the primary suggester of the type fails.
+	Fallbacks []SuggesterFallback
+	// Lanes, when set, bound the aggregations run at once, giving the interactive requests priority over the bulk ones.
+	Lanes *Lanes
+	// Segmentation, when set, splits the body of big content into chunks sent to the suggesters separately.
+	Segmentation *Segmentation
+	// ConcordanceFailureMode is what happens to the suggestions when internal concordances fails. The zero value fails the request.
+	ConcordanceFailureMode ConcordanceFailureMode
+	Log                    *logger.UPPLogger
+	inFlight               flightGroup
+}
+
+// SuggestionOptions tune how the suggestions are aggregated for a single request.
+type SuggestionOptions struct {
+	// NoCache skips any cached suggestions for the payload. The fresh suggestions are cached nonetheless.
+	NoCache bool
+	// BroaderPolicy and BroaderDepth, when set, override the configured broader concepts policy for every concept type.
+	BroaderPolicy BroaderPolicy
+	BroaderDepth  BroaderDepth
+	// IncludeImplied adds the broader concepts implied by the suggestions, as configured in the broader concepts provider.
+	IncludeImplied bool
+	// IncludeDetails attaches the details of the concepts, taken from the same public things lookup as their broader concepts.
+	IncludeDetails bool
+	// Merge asks for the suggestions of the same concept to be merged when the merger only merges on request.
+	Merge bool
+	// Lane is the priority of the request when the aggregations are run in lanes. It doesn't change the suggestions.
+	Lane Lane
+}
+
+// key identifies the suggestions produced for the transformed payload with these options.
+func (o SuggestionOptions) key(data []byte) string {
+	o.NoCache = false
+	o.Lane = ""
+	if o == (SuggestionOptions{}) {
+		return payloadHash(data)
+	}
+	return fmt.Sprintf("%v:%+v", payloadHash(data), o)
 }
 
 func NewAggregateSuggester(log *logger.UPPLogger, concordance *ConcordanceService, broaderConceptsProvider *BroaderConceptsProvider, blacklister ConceptBlacklister, suggesters ...Suggester) *AggregateSuggester {
@@ -29,6 +85,16 @@ func NewAggregateSuggester(log *logger.UPPLogger, concordance *ConcordanceServic
 }
 
 func (s *AggregateSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	return s.GetSuggestionsWithOptions(payload, tid, SuggestionOptions{})
+}
+
+func (s *AggregateSuggester) GetSuggestionsWithOptions(payload []byte, tid string, opts SuggestionOptions) (SuggestionsResponse, error) {
+	return s.GetSuggestionsWithContext(context.Background(), payload, tid, opts)
+}
+
+// GetSuggestionsWithContext gives up waiting for a turn in the lanes when the context is done, and runs the aggregation in the slot
+// the context holds, if any. The aggregations shared by identical requests wait with the context of the first one.
+func (s *AggregateSuggester) GetSuggestionsWithContext(ctx context.Context, payload []byte, tid string, opts SuggestionOptions) (SuggestionsResponse, error) {
 	logEntry := s.Log.WithTransactionID(tid)
 
 	data, err := getXmlSuggestionRequestFromJson(payload)
@@ -38,17 +104,87 @@ func (s *AggregateSuggester) GetSuggestions(payload []byte, tid string) (Suggest
 
 	logEntry.Debugf("transformed payload: %s", string(data))
 
+	key := opts.key(data)
+	if s.Cache != nil && !opts.NoCache {
+		if resp, ok := s.Cache.Get(key); ok {
+			logEntry.Debugf("Serving cached suggestions for payload hash %v", key)
+			return resp, nil
+		}
+	}
+
+	var generation uint64
+	if s.Cache != nil {
+		generation = s.Cache.generation()
+	}
+	aggregate := func() (interface{}, error) {
+		resp, complete, err := s.aggregateSuggestions(data, opts, tid)
+		if err == nil && s.Cache != nil {
+			if complete {
+				s.Cache.setIfCurrent(key, resp, generation)
+			} else {
+				logEntry.Debug("Not caching suggestions as some of the downstream services failed")
+			}
+		}
+		return resp, err
+	}
+	flightKey := key
+	if s.Lanes != nil {
+		// requests only share the aggregations of their own lane, so interactive requests don't wait in the bulk queue
+		flightKey = string(opts.Lane.priority()) + ":" + key
+		runInLane := aggregate
+		aggregate = func() (interface{}, error) {
+			return s.Lanes.run(ctx, opts.Lane, runInLane)
+		}
+	}
+
+	// identical payloads submitted at the same time share a single aggregation
+	result, err, shared := s.inFlight.do(flightKey, aggregate)
+	if shared {
+		logEntry.Debugf("Shared in-flight suggestions for payload hash %v", key)
+	}
+	resp, ok := result.(SuggestionsResponse)
+	if !ok {
+		return SuggestionsResponse{Suggestions: make([]Suggestion, 0)}, err
+	}
+	return copySuggestionsResponse(resp), err
+}
+
+// aggregateSuggestions returns the suggestions for the transformed payload and whether all the downstream services contributed to them.
+func (s *AggregateSuggester) aggregateSuggestions(data []byte, opts SuggestionOptions, tid string) (SuggestionsResponse, bool, error) {
+	logEntry := s.Log.WithTransactionID(tid)
+	broaderPolicy := s.BroaderProvider.Policy.WithOverrides(opts.BroaderPolicy, opts.BroaderDepth)
+
 	var aggregateResp = SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	var complete = true
 	var responseMap = map[int][]Suggestion{}
+	var errs = map[int]error{}
 
 	var mutex = sync.Mutex{}
 	var wg = sync.WaitGroup{}
 
+	var chunks [][]byte
+	if s.Segmentation != nil {
+		var truncated bool
+		chunks, truncated = s.Segmentation.split(data)
+		if truncated {
+			logEntry.Warnf("Body truncated to %v chunks of %v bytes", s.Segmentation.MaxChunks, s.Segmentation.ChunkSize)
+		} else if chunks != nil {
+			logEntry.Debugf("Body split into %v chunks", len(chunks))
+		}
+	}
+
 	for key, suggesterDelegate := range s.Suggesters {
 		wg.Add(1)
 		logEntry := logEntry
 		go func(i int, delegate Suggester) {
-			resp, sErr := delegate.GetSuggestions(data, tid)
+			var resp SuggestionsResponse
+			var sErr error
+			if chunks != nil {
+				resp, sErr = s.Segmentation.suggest(delegate, chunks, tid)
+			} else {
+				resp, sErr = delegate.GetSuggestions(data, tid)
+			}
+			failed := false
 			if sErr != nil {
 				errMsg := "error calling " + delegate.GetName()
 				errEntry := logEntry.WithError(sErr)
@@ -56,10 +192,20 @@ func (s *AggregateSuggester) GetSuggestions(payload []byte, tid string) (Suggest
 					errEntry.Warn(errMsg)
 				} else {
 					errEntry.Error(errMsg)
+					failed = true
 				}
 			}
+			if len(resp.Degraded) > 0 {
+				logEntry.Warnf("%v suggestions are degraded: %v", delegate.GetName(), resp.Degraded)
+				failed = true
+			}
 			mutex.Lock()
 			responseMap[i] = resp.Suggestions
+			errs[i] = sErr
+			aggregateResp.Degraded = append(aggregateResp.Degraded, resp.Degraded...)
+			if failed {
+				complete = false
+			}
 			mutex.Unlock()
 			wg.Done()
 		}(key, suggesterDelegate)
@@ -68,43 +214,161 @@ func (s *AggregateSuggester) GetSuggestions(payload []byte, tid string) (Suggest
 
 	var blacklist Blacklist
 	wg.Add(1)
-	go func(b Blacklist) {
+	go func() {
 		defer wg.Done()
-		blacklist, err = s.Blacklister.GetBlacklist(tid)
-		if err != nil {
-			logEntry.WithError(err).Errorf("Error retrieving concept blacklist, filtering disabled")
+		var bErr error
+		blacklist, bErr = s.Blacklister.GetBlacklist(tid)
+		if bErr != nil {
+			logEntry.WithError(bErr).Errorf("Error retrieving concept blacklist, filtering disabled")
+			mutex.Lock()
+			complete = false
+			mutex.Unlock()
 		}
-	}(blacklist)
+	}()
 
 	wg.Wait()
 
-	responseMap, err = s.filterByInternalConcordances(responseMap, tid)
+	// Look up the broader concepts with the suggested UUIDs while they are being concorded, rather than afterwards.
+	// Most suggestions are already canonical, so the concorded ones rarely need a second lookup.
+	broaderIndex := newBroaderIndex()
+	broaderDone := make(chan struct{})
+	ids := suggestionIDs(responseMap)
+	go func() {
+		defer close(broaderDone)
+		if len(ids) == 0 {
+			return
+		}
+		broader, bErr := s.BroaderProvider.getBroaderConcepts(ids, broaderPolicy.depth(), tid)
+		if bErr != nil {
+			logEntry.WithError(bErr).Debug("Speculative broader concepts lookup failed, looking up the concorded concepts instead")
+			return
+		}
+		broaderIndex.add(ids, broader)
+	}()
+
+	concordedMap, err := s.filterByInternalConcordances(responseMap, tid)
 	if err != nil {
-		return aggregateResp, err
+		fallbackMap, degradations, ok := s.fallbackConcordances(responseMap, tid)
+		if !ok {
+			<-broaderDone
+			return aggregateResp, false, err
+		}
+		logEntry.WithError(err).Warnf("Error calling internal concordances, falling back to %v suggestions", s.ConcordanceFailureMode)
+		aggregateResp.Degraded = append(aggregateResp.Degraded, degradations...)
+		complete = false
+		concordedMap = fallbackMap
+	}
+	responseMap = concordedMap
+
+	// the fallbacks are promoted by the concorded types, which the suggesters may not know
+	if s.promoteFallbacks(responseMap, errs, tid) {
+		aggregateResp.Degraded = append(aggregateResp.Degraded, DegradedSuggesterFallback)
 	}
 
 	for key, suggesterDelegate := range s.Suggesters {
 		if len(responseMap[key]) > 0 {
+			total := len(responseMap[key])
 			responseMap[key] = suggesterDelegate.FilterSuggestions(responseMap[key])
+			countDropped(dropReasonUntrustedType, total-len(responseMap[key]))
 		}
 	}
 
-	results, err := s.BroaderProvider.excludeBroaderConceptsFromResponse(responseMap, tid)
+	<-broaderDone
+	// the concepts excluded by the broader policy aren't added back as implied concepts either
+	excluded := map[string]bool{}
+	results, err := s.BroaderProvider.applyBroaderPolicy(responseMap, broaderIndex, broaderPolicy, tid)
 	if err != nil {
-		logEntry.WithError(err).Warn("Couldn't exclude broader concepts. Response might contain broader concepts as well")
+		logEntry.WithError(err).Warn("Couldn't apply the broader concepts policy. Response might contain broader concepts as well")
+		complete = false
 	} else {
+		for _, id := range suggestionIDs(responseMap) {
+			excluded[id] = true
+		}
+		for _, id := range suggestionIDs(results) {
+			delete(excluded, id)
+		}
+		countDropped(dropReasonBroader, suggestionCount(responseMap)-suggestionCount(results))
 		responseMap = results
 	}
+	if opts.IncludeDetails {
+		responseMap = broaderIndex.withDetails(responseMap)
+	}
 
 	// preserve results order
+	merge := s.Merger != nil && (!s.MergeOptIn || opts.Merge)
 	for i := 0; i < len(s.Suggesters); i++ {
 		for _, suggestion := range responseMap[i] {
-			if !s.Blacklister.IsBlacklisted(suggestion.ID, blacklist) {
+			if !s.vetoed(suggestion, blacklist, tid) {
+				if merge && len(suggestion.Sources) == 0 {
+					suggestion.Sources = []string{s.Suggesters[i].GetName()}
+				}
 				aggregateResp.Suggestions = append(aggregateResp.Suggestions, suggestion)
 			}
 		}
 	}
-	return aggregateResp, nil
+	if merge {
+		total := len(aggregateResp.Suggestions)
+		aggregateResp.Suggestions = s.Merger.Merge(aggregateResp.Suggestions)
+		logEntry.Debugf("Merged %v suggestions into %v distinct concepts", total, len(aggregateResp.Suggestions))
+	}
+	aggregateResp.Suggestions = boostRuleConcepts(aggregateResp.Suggestions)
+	aggregateResp.Suggestions = demoteBroaderConcepts(aggregateResp.Suggestions, broaderPolicy)
+
+	if opts.IncludeImplied && len(s.BroaderProvider.Implied.Types) > 0 {
+		implied, err := s.BroaderProvider.impliedSuggestions(aggregateResp.Suggestions, excluded, tid)
+		if err != nil {
+			logEntry.WithError(err).Warn("Couldn't add the implied broader concepts")
+			complete = false
+		}
+		for _, suggestion := range implied {
+			if !s.vetoed(suggestion, blacklist, tid) {
+				aggregateResp.Suggestions = append(aggregateResp.Suggestions, suggestion)
+			}
+		}
+	}
+	return aggregateResp, complete, nil
+}
+
+// vetoed reports whether the suggestion is blacklisted, and records the entry vetoing it.
+func (s *AggregateSuggester) vetoed(suggestion Suggestion, blacklist Blacklist, tid string) bool {
+	if !s.Blacklister.IsBlacklisted(suggestion.ID, blacklist) {
+		return false
+	}
+	entry, _ := blacklist.Entry(suggestion.ID, time.Now())
+	logEntry := s.Log.WithTransactionID(tid)
+	if entry.Reason == "" {
+		logEntry.Infof("Vetoed suggestion %v (%v), blacklisted without reason", suggestion.ID, suggestion.PrefLabel)
+	} else {
+		logEntry.Infof("Vetoed suggestion %v (%v), blacklisted by %v at %v: %v", suggestion.ID, suggestion.PrefLabel, entry.Author, entry.CreatedAt.Format(time.RFC3339), entry.Reason)
+	}
+	metrics.GetOrRegisterCounter("blacklist.vetoes", metrics.DefaultRegistry).Inc(1)
+	metrics.GetOrRegisterCounter("blacklist.vetoes."+vetoReason(entry.Reason), metrics.DefaultRegistry).Inc(1)
+	countDropped(dropReasonBlacklisted, 1)
+	return true
+}
+
+// vetoReason returns the reason of a blacklist entry as a metric name segment, e.g. legal-hold for "Legal hold".
+func vetoReason(reason string) string {
+	reason = strings.Trim(vetoReasonRegex.ReplaceAllString(strings.ToLower(reason), "-"), "-")
+	if reason == "" {
+		return "unspecified"
+	}
+	return reason
+}
+
+// countDropped counts the suggestions dropped for the reason.
+func countDropped(reason string, count int) {
+	if count > 0 {
+		metrics.GetOrRegisterCounter("suggestions.dropped."+reason, metrics.DefaultRegistry).Inc(int64(count))
+	}
+}
+
+func suggestionCount(suggestions map[int][]Suggestion) int {
+	count := 0
+	for _, sourceSuggestions := range suggestions {
+		count += len(sourceSuggestions)
+	}
+	return count
 }
 
 func (s *AggregateSuggester) filterByInternalConcordances(suggestions map[int][]Suggestion, tid string) (map[int][]Suggestion, error) {
@@ -135,26 +399,93 @@ func (s *AggregateSuggester) filterByInternalConcordances(suggestions map[int][]
 	}
 
 	total := 0
+	var dropped []string
 	for index, suggestions := range suggestions {
 		filtered[index] = []Suggestion{}
 		for _, suggestion := range suggestions {
 			id := fp.Base(suggestion.Concept.ID)
 			c, ok := concorded.Concepts[id]
-			if ok {
-				filtered[index] = append(filtered[index], Suggestion{
-					Predicate: suggestion.Predicate,
-					Concept:   c,
-				})
+			if !ok {
+				dropped = append(dropped, suggestion.Concept.ID)
+				continue
+			}
+			if fp.Base(c.ID) != id {
+				logEntry.Debugf("Suggested concept %v is concorded to %v", suggestion.Concept.ID, c.ID)
+			}
+			if c.IsDeprecated {
+				logEntry.Debugf("Suggested concept %v is deprecated", c.ID)
 			}
+			filtered[index] = append(filtered[index], concordedSuggestion(suggestion, c))
 		}
 		total += len(filtered[index])
 	}
 
 	logEntry.Debugf("Retained %v of %v concepts using concordances", total, len(ids))
+	if len(dropped) > 0 {
+		logEntry.Infof("Dropped suggestions without concordance (unknown or deprecated concepts): %v", dropped)
+		countDropped(dropReasonUnconcorded, len(dropped))
+	}
 
 	return filtered, nil
 }
 
+// fallbackConcordances returns the suggestions to fall back on when internal concordances fails, as allowed by the concordance failure mode,
+// along with how they are degraded. In cache mode, the suggestions whose concordance isn't cached are returned unconcorded.
+func (s *AggregateSuggester) fallbackConcordances(suggestions map[int][]Suggestion, tid string) (map[int][]Suggestion, []string, bool) {
+	mode := s.ConcordanceFailureMode
+	if mode != ConcordanceFailureModeUnconcorded && mode != ConcordanceFailureModeCache {
+		return nil, nil, false
+	}
+	logEntry := s.Log.WithTransactionID(tid)
+
+	var degradations []string
+	if mode == ConcordanceFailureModeCache {
+		degradations = append(degradations, DegradedConcordanceCache)
+	}
+
+	fallback := map[int][]Suggestion{}
+	cached, unconcorded := 0, 0
+	for index, sourceSuggestions := range suggestions {
+		fallback[index] = []Suggestion{}
+		for _, suggestion := range sourceSuggestions {
+			if mode == ConcordanceFailureModeCache && s.Concordance.Cache != nil {
+				if c, ok := s.Concordance.Cache.Get(fp.Base(suggestion.ID)); ok {
+					fallback[index] = append(fallback[index], concordedSuggestion(suggestion, c))
+					cached++
+					continue
+				}
+			}
+			suggestion.Unconcorded = true
+			fallback[index] = append(fallback[index], suggestion)
+			unconcorded++
+		}
+	}
+	if unconcorded > 0 {
+		degradations = append(degradations, DegradedUnconcorded)
+	}
+
+	logEntry.Infof("Falling back to %v suggestions concorded from cache and %v unconcorded suggestions", cached, unconcorded)
+	metrics.GetOrRegisterCounter("concordance.failures."+string(mode), metrics.DefaultRegistry).Inc(1)
+	metrics.GetOrRegisterCounter("concordance.fallback.cached", metrics.DefaultRegistry).Inc(int64(cached))
+	metrics.GetOrRegisterCounter("concordance.fallback.unconcorded", metrics.DefaultRegistry).Inc(int64(unconcorded))
+	return fallback, degradations, true
+}
+
+// concordedSuggestion replaces the suggested concept by its concorded concept,
+// recording the suggested identifier when it was merged into, or is a source identifier of, another concept.
+func concordedSuggestion(suggestion Suggestion, c Concept) Suggestion {
+	concorded := Suggestion{
+		Predicate:  suggestion.Predicate,
+		Concept:    c,
+		Provenance: suggestion.Provenance,
+		Sources:    suggestion.Sources,
+	}
+	if fp.Base(c.ID) != fp.Base(suggestion.ID) {
+		concorded.OriginalID = suggestion.ID
+	}
+	return concorded
+}
+
 func dedup(s []string) []string {
 	seen := make(map[string]struct{}, len(s))
 	j := 0
@@ -168,3 +499,12 @@ func dedup(s []string) []string {
 	}
 	return s[:j]
 }
+
+func contains(s []string, value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -9,9 +9,13 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -752,3 +756,665 @@ func TestAggregateSuggester_GetSuggestionsWithBlacklistError(t *testing.T) {
 
 	suggestionApi.AssertExpectations(t)
 }
+
+type stubHttpClient func(req *http.Request) (*http.Response, error)
+
+func (c stubHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return c(req)
+}
+
+func jsonResponder(body string) stubHttpClient {
+	return func(*http.Request) (*http.Response, error) {
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader(body)), StatusCode: http.StatusOK}, nil
+	}
+}
+
+// newTestAggregateSuggester builds an aggregator whose concordance resolves the given concepts,
+// with no broader concepts and an empty blacklist.
+func newTestAggregateSuggester(t *testing.T, concepts map[string]Concept, suggesters ...Suggester) *AggregateSuggester {
+	concordanceBody, err := json.Marshal(ConcordanceResponse{Concepts: concepts})
+	require.NoError(t, err)
+
+	return NewAggregateSuggester(logger.NewUPPLogger("test-service", "panic"),
+		NewConcordance("internalConcordancesHost", "/internalconcordances", jsonResponder(string(concordanceBody))),
+		NewBroaderConceptsProvider("publicThingsUrl", "/things", jsonResponder(`{"things":{}}`)),
+		NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", jsonResponder(`{"uuids":[]}`)),
+		suggesters...)
+}
+
+func TestAggregateSuggester_GetSuggestionsCached(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	suggestionApi := new(mockSuggestionApi)
+	suggestionApi.On("GetSuggestions", mock.AnythingOfType("[]uint8"), "tid_test").Return(SuggestionsResponse{Suggestions: []Suggestion{{Concept: london}}}, nil).Once()
+	suggestionApi.On("FilterSuggestions", mock.Anything).Return([]Suggestion{{Concept: london}}).Once()
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"f758ef56-c40a-3162-91aa-3e8a3aabc495": london}, suggestionApi)
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	first, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	// same cleaned payload, different markup
+	second, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body><p>London</p></body>"}`), "tid_test")
+	expect.NoError(err)
+
+	expect.Equal(first, second)
+	expect.Equal([]Suggestion{{Concept: london}}, second.Suggestions)
+	suggestionApi.AssertExpectations(t)
+}
+
+func TestAggregateSuggester_GetSuggestionsNoCache(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	suggestionApi := new(mockSuggestionApi)
+	suggestionApi.On("GetSuggestions", mock.AnythingOfType("[]uint8"), "tid_test").Return(SuggestionsResponse{Suggestions: []Suggestion{{Concept: london}}}, nil).Twice()
+	suggestionApi.On("FilterSuggestions", mock.Anything).Return([]Suggestion{{Concept: london}}).Twice()
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"f758ef56-c40a-3162-91aa-3e8a3aabc495": london}, suggestionApi)
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	_, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	resp, err := aggregateSuggester.GetSuggestionsWithOptions(payload, "tid_test", SuggestionOptions{NoCache: true})
+	expect.NoError(err)
+
+	expect.Equal([]Suggestion{{Concept: london}}, resp.Suggestions)
+	suggestionApi.AssertExpectations(t)
+}
+
+func TestAggregateSuggester_GetSuggestionsNotCachedWhenSuggesterFails(t *testing.T) {
+	expect := assert.New(t)
+
+	suggestionApi := new(mockSuggestionApi)
+	suggestionApi.On("GetSuggestions", mock.AnythingOfType("[]uint8"), "tid_test").Return(SuggestionsResponse{}, errors.New("Ontotext err")).Twice()
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{}, suggestionApi)
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	_, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	_, err = aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+
+	expect.Equal(0, aggregateSuggester.Cache.cache.len())
+	suggestionApi.AssertExpectations(t)
+}
+
+type blockingSuggester struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (b *blockingSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	return SuggestionsResponse{Suggestions: []Suggestion{}}, nil
+}
+
+func (b *blockingSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return suggestions
+}
+
+func (b *blockingSuggester) GetName() string {
+	return "Blocking Suggestion API"
+}
+
+func TestAggregateSuggester_GetSuggestionsCollapsesConcurrentIdenticalPayloads(t *testing.T) {
+	expect := assert.New(t)
+
+	suggester := &blockingSuggester{release: make(chan struct{})}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{}, suggester)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+			expect.NoError(err)
+			expect.NotNil(resp.Suggestions)
+		}()
+	}
+	// give all the requests time to join the in-flight aggregation
+	time.Sleep(50 * time.Millisecond)
+	close(suggester.release)
+	wg.Wait()
+
+	expect.Equal(int32(1), atomic.LoadInt32(&suggester.calls))
+}
+
+func TestAggregateSuggester_GetSuggestionsExcludesBroaderOfConcordedConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	apple := Concept{ID: "http://www.ft.com/thing/ca26a873-dd01-11e8-9a17-6c96cfdf3997", PrefLabel: "Apple", Type: ontologyOrganisationType}
+	company := Concept{ID: "http://www.ft.com/thing/c9e114c1-dd01-11e8-8d2b-6c96cfdf3997", PrefLabel: "Company", Type: ontologyOrganisationType}
+	suggestions := []Suggestion{
+		{Concept: Concept{ID: "http://www.ft.com/thing/source-apple-uuid"}},
+		{Concept: company},
+	}
+	suggestionApi := new(mockSuggestionApi)
+	suggestionApi.On("GetSuggestions", mock.AnythingOfType("[]uint8"), "tid_test").Return(SuggestionsResponse{Suggestions: suggestions}, nil)
+	suggestionApi.On("FilterSuggestions", mock.Anything).Return([]Suggestion{{Concept: apple}, {Concept: company}})
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"source-apple-uuid":                    apple,
+		"c9e114c1-dd01-11e8-8d2b-6c96cfdf3997": company,
+	}, suggestionApi)
+
+	var thingsCalls int32
+	aggregateSuggester.BroaderProvider.Client = stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&thingsCalls, 1)
+		expect.ElementsMatch([]string{"source-apple-uuid", "c9e114c1-dd01-11e8-8d2b-6c96cfdf3997"}, req.URL.Query()["uuid"])
+		return jsonResponder(`{"things":{"source-apple-uuid":{"id":"` + apple.ID + `","broaderConcepts":[{"id":"` + company.ID + `"}]}}}`)(req)
+	})
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>Apple</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: apple}}, response.Suggestions)
+	// the speculative lookup made with the suggested UUIDs covers the concorded concepts
+	expect.Equal(int32(1), atomic.LoadInt32(&thingsCalls))
+}
+
+func TestAggregateSuggester_GetSuggestionsLooksUpBroaderAgainWhenSpeculativeLookupFails(t *testing.T) {
+	expect := assert.New(t)
+
+	apple := Concept{ID: "http://www.ft.com/thing/ca26a873-dd01-11e8-9a17-6c96cfdf3997", PrefLabel: "Apple", Type: ontologyOrganisationType}
+	company := Concept{ID: "http://www.ft.com/thing/c9e114c1-dd01-11e8-8d2b-6c96cfdf3997", PrefLabel: "Company", Type: ontologyOrganisationType}
+	suggestionApi := new(mockSuggestionApi)
+	suggestionApi.On("GetSuggestions", mock.AnythingOfType("[]uint8"), "tid_test").Return(SuggestionsResponse{Suggestions: []Suggestion{
+		{Concept: Concept{ID: "http://www.ft.com/thing/source-apple-uuid"}},
+		{Concept: company},
+	}}, nil)
+	suggestionApi.On("FilterSuggestions", mock.Anything).Return([]Suggestion{{Concept: apple}, {Concept: company}})
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"source-apple-uuid":                    apple,
+		"c9e114c1-dd01-11e8-8d2b-6c96cfdf3997": company,
+	}, suggestionApi)
+
+	var thingsCalls int32
+	aggregateSuggester.BroaderProvider.Client = stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&thingsCalls, 1) == 1 {
+			return &http.Response{Body: ioutil.NopCloser(strings.NewReader("")), StatusCode: http.StatusBadRequest}, nil
+		}
+		expect.ElementsMatch([]string{"ca26a873-dd01-11e8-9a17-6c96cfdf3997", "c9e114c1-dd01-11e8-8d2b-6c96cfdf3997"}, req.URL.Query()["uuid"])
+		return jsonResponder(`{"things":{"ca26a873-dd01-11e8-9a17-6c96cfdf3997":{"broaderConcepts":[{"id":"` + company.ID + `"}]}}}`)(req)
+	})
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>Apple</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: apple}}, response.Suggestions)
+	expect.Equal(int32(2), atomic.LoadInt32(&thingsCalls))
+}
+
+// delayedResponder simulates the latency of a downstream service.
+func delayedResponder(delay time.Duration, body string) stubHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		time.Sleep(delay)
+		return jsonResponder(body)(req)
+	}
+}
+
+func newBenchmarkAggregateSuggester(b *testing.B, latency time.Duration) *AggregateSuggester {
+	concepts := map[string]Concept{}
+	var suggestions []Suggestion
+	for i := 0; i < 20; i++ {
+		uuid := fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+		concept := Concept{ID: "http://www.ft.com/thing/" + uuid, PrefLabel: uuid, Type: ontologyOrganisationType}
+		concepts[uuid] = concept
+		suggestions = append(suggestions, Suggestion{Concept: concept})
+	}
+	concordanceBody, err := json.Marshal(ConcordanceResponse{Concepts: concepts})
+	require.NoError(b, err)
+
+	suggester := &stubSuggester{suggestions: suggestions}
+	return NewAggregateSuggester(logger.NewUPPLogger("test-service", "panic"),
+		NewConcordance("internalConcordancesHost", "/internalconcordances", delayedResponder(latency, string(concordanceBody))),
+		NewBroaderConceptsProvider("publicThingsUrl", "/things", delayedResponder(latency, `{"things":{}}`)),
+		NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", jsonResponder(`{"uuids":[]}`)),
+		suggester)
+}
+
+type stubSuggester struct {
+	name        string
+	suggestions []Suggestion
+}
+
+func (s *stubSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	return SuggestionsResponse{Suggestions: s.suggestions}, nil
+}
+
+func (s *stubSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return suggestions
+}
+
+func (s *stubSuggester) GetName() string {
+	if s.name == "" {
+		return "Stub Suggestion API"
+	}
+	return s.name
+}
+
+func reportP99(b *testing.B, durations []time.Duration) {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	b.ReportMetric(float64(durations[len(durations)*99/100].Microseconds()), "p99-µs")
+}
+
+// BenchmarkAggregateSuggester_sequentialBroaderLookup is the baseline of the broader concepts being looked up after concordance.
+func BenchmarkAggregateSuggester_sequentialBroaderLookup(b *testing.B) {
+	s := newBenchmarkAggregateSuggester(b, 5*time.Millisecond)
+	durations := make([]time.Duration, 0, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		resp, _ := s.Suggesters[0].GetSuggestions(nil, "tid_bench")
+		concorded, err := s.filterByInternalConcordances(map[int][]Suggestion{0: resp.Suggestions}, "tid_bench")
+		require.NoError(b, err)
+		_, err = s.BroaderProvider.applyBroaderPolicy(concorded, newBroaderIndex(), BroaderConceptsPolicy{Default: BroaderPolicyExclude}, "tid_bench")
+		require.NoError(b, err)
+		durations = append(durations, time.Since(start))
+	}
+	reportP99(b, durations)
+}
+
+func BenchmarkAggregateSuggester_aggregateSuggestions(b *testing.B) {
+	s := newBenchmarkAggregateSuggester(b, 5*time.Millisecond)
+	durations := make([]time.Duration, 0, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		_, _, err := s.aggregateSuggestions(nil, SuggestionOptions{}, "tid_bench")
+		require.NoError(b, err)
+		durations = append(durations, time.Since(start))
+	}
+	reportP99(b, durations)
+}
+
+func TestAggregateSuggester_GetSuggestionsMergesDuplicateConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	summers := Concept{ID: "http://www.ft.com/thing/9a5e3b4a-55da-498c-816f-9c534e1392b6", PrefLabel: "Lawrence Summers", Type: ontologyPersonType, IsFTAuthor: true}
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	authors := &stubSuggester{name: "Authors", suggestions: []Suggestion{{Concept: summers, Predicate: predicateHasAuthor}}}
+	ontotext := &stubSuggester{name: "Ontotext", suggestions: []Suggestion{
+		{Concept: london},
+		// a source identifier concorded to the same author
+		{Concept: Concept{ID: "http://www.ft.com/thing/source-summers-uuid"}},
+	}}
+
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"9a5e3b4a-55da-498c-816f-9c534e1392b6": summers,
+		"source-summers-uuid":                  summers,
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+	}, authors, ontotext)
+	aggregateSuggester.Merger = NewSuggestionsMerger([]string{predicateHasAuthor})
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: summers, Predicate: predicateHasAuthor, Sources: []string{"Authors", "Ontotext"}},
+		{Concept: london, Sources: []string{"Ontotext"}},
+	}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsMergesOnRequest(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	ontotext := &stubSuggester{name: "Ontotext", suggestions: []Suggestion{{Concept: london}}}
+	gazetteer := &stubSuggester{name: "Gazetteer", suggestions: []Suggestion{{Concept: london}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"f758ef56-c40a-3162-91aa-3e8a3aabc495": london}, ontotext, gazetteer)
+	aggregateSuggester.Merger = NewSuggestionsMerger(nil)
+	aggregateSuggester.MergeOptIn = true
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	response, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london}, {Concept: london}}, response.Suggestions)
+
+	response, err = aggregateSuggester.GetSuggestionsWithOptions(payload, "tid_test", SuggestionOptions{Merge: true})
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london, Sources: []string{"Ontotext", "Gazetteer"}}}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsWithImpliedConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	paris := Concept{ID: "http://www.ft.com/thing/paris-uuid", PrefLabel: "Paris", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: paris}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"paris-uuid": paris}, suggester)
+	aggregateSuggester.BroaderProvider.Client = stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		if len(req.URL.Query()["showRelationship"]) > 1 {
+			return jsonResponder(`{"things":{}}`)(req)
+		}
+		return jsonResponder(`{"things":{"paris-uuid":{"broaderConcepts":[
+			{"id":"http://www.ft.com/thing/france-uuid","prefLabel":"France"},
+			{"id":"http://www.ft.com/thing/ile-de-france-uuid","prefLabel":"Île-de-France"}
+		]}}}`)(req)
+	})
+	aggregateSuggester.BroaderProvider.Implied = ImpliedConceptsPolicy{Types: []string{ontologyLocationType}, Depth: 1}
+	aggregateSuggester.Blacklister = NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", jsonResponder(`{"uuids":["ile-de-france-uuid"]}`))
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	payload := []byte(`{"bodyXML":"<body>Paris</body>"}`)
+	response, err := aggregateSuggester.GetSuggestionsWithOptions(payload, "tid_test", SuggestionOptions{IncludeImplied: true})
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: paris},
+		{Concept: Concept{ID: "http://www.ft.com/thing/france-uuid", PrefLabel: "France", Type: ontologyLocationType}, Provenance: ProvenanceImplied, ImpliedBy: []string{paris.ID}},
+	}, response.Suggestions)
+
+	// implied suggestions are cached apart from the plain ones
+	response, err = aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: paris}}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsDoesNotImplyExcludedConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	paris := Concept{ID: "http://www.ft.com/thing/paris-uuid", PrefLabel: "Paris", Type: ontologyLocationType}
+	france := Concept{ID: "http://www.ft.com/thing/france-uuid", PrefLabel: "France", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: paris}, {Concept: france}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"paris-uuid": paris, "france-uuid": france}, suggester)
+	aggregateSuggester.BroaderProvider.Client = jsonResponder(`{"things":{"paris-uuid":{"broaderConcepts":[
+		{"id":"http://www.ft.com/thing/france-uuid","prefLabel":"France"}
+	]}}}`)
+	aggregateSuggester.BroaderProvider.Implied = ImpliedConceptsPolicy{Types: []string{ontologyLocationType}, Depth: 1}
+
+	response, err := aggregateSuggester.GetSuggestionsWithOptions([]byte(`{"bodyXML":"<body>Paris, France</body>"}`), "tid_test", SuggestionOptions{IncludeImplied: true})
+
+	expect.NoError(err)
+	// France is excluded as broader than Paris, so it isn't implied by Paris either
+	expect.Equal([]Suggestion{{Concept: paris}}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsReportsConcordedAndDeprecatedConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	oldApple := Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55", PrefLabel: "Apple", Type: ontologyOrganisationType, IsDeprecated: true}
+	suggester := &stubSuggester{suggestions: []Suggestion{
+		{Concept: Concept{ID: "http://www.ft.com/thing/merged-london-uuid"}, Predicate: predicateMentions},
+		{Concept: oldApple},
+		{Concept: Concept{ID: "http://www.ft.com/thing/unknown-uuid"}},
+	}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"merged-london-uuid":                   london,
+		"9332270e-f959-3f55-9153-d30acd0d0a55": oldApple,
+	}, suggester)
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: london, Predicate: predicateMentions, OriginalID: "http://www.ft.com/thing/merged-london-uuid"},
+		{Concept: oldApple},
+	}, response.Suggestions)
+}
+
+func failingConcordanceClient() stubHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader("")), StatusCode: http.StatusServiceUnavailable}, nil
+	}
+}
+
+func TestAggregateSuggester_GetSuggestionsUnconcordedWhenConcordanceFails(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: london}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{}, suggester)
+	aggregateSuggester.Concordance.Client = failingConcordanceClient()
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+	expect.EqualError(err, "non 200 status code returned: 503")
+
+	aggregateSuggester.ConcordanceFailureMode = ConcordanceFailureModeUnconcorded
+	unconcorded := metrics.GetOrRegisterCounter("concordance.fallback.unconcorded", metrics.DefaultRegistry).Count()
+	response, err = aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal(SuggestionsResponse{
+		Suggestions: []Suggestion{{Concept: london, Unconcorded: true}},
+		Degraded:    []string{DegradedUnconcorded},
+	}, response)
+	expect.Equal(unconcorded+1, metrics.GetOrRegisterCounter("concordance.fallback.unconcorded", metrics.DefaultRegistry).Count())
+	// degraded suggestions aren't cached
+	expect.Equal(0, aggregateSuggester.Cache.cache.len())
+}
+
+func TestAggregateSuggester_GetSuggestionsFromConcordanceCacheWhenConcordanceFails(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	apple := Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55", PrefLabel: "Apple", Type: ontologyOrganisationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: Concept{ID: "http://www.ft.com/thing/source-london-uuid"}}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"source-london-uuid": london}, suggester)
+	aggregateSuggester.Concordance.Cache = NewConceptsCache(10, time.Minute)
+	aggregateSuggester.ConcordanceFailureMode = ConcordanceFailureModeCache
+
+	// a successful lookup fills the cache
+	_, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London</body>"}`), "tid_test")
+	expect.NoError(err)
+
+	aggregateSuggester.Concordance.Client = failingConcordanceClient()
+	suggester.suggestions = append(suggester.suggestions, Suggestion{Concept: apple})
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London and Apple</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal(SuggestionsResponse{
+		Suggestions: []Suggestion{
+			{Concept: london, OriginalID: "http://www.ft.com/thing/source-london-uuid"},
+			{Concept: apple, Unconcorded: true},
+		},
+		Degraded: []string{DegradedConcordanceCache, DegradedUnconcorded},
+	}, response)
+}
+
+func TestAggregateSuggester_GetSuggestionsWithDetails(t *testing.T) {
+	expect := assert.New(t)
+
+	apple := Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55", PrefLabel: "Apple", Type: ontologyOrganisationType}
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: Concept{ID: "http://www.ft.com/thing/source-apple-uuid"}}, {Concept: london}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"source-apple-uuid":                    apple,
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+	}, suggester)
+
+	var thingsCalls int32
+	aggregateSuggester.BroaderProvider.Client = stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&thingsCalls, 1)
+		return jsonResponder(`{"things":{
+			"source-apple-uuid":{"id":"` + apple.ID + `","aliases":["Apple Inc","Apple Computer"],"descriptionXML":"<p>Maker of the iPhone</p>","ticker":"AAPL","figiCode":"BBG000B9XRY4"},
+			"f758ef56-c40a-3162-91aa-3e8a3aabc495":{"id":"` + london.ID + `"}
+		}}`)(req)
+	})
+
+	response, err := aggregateSuggester.GetSuggestionsWithOptions([]byte(`{"bodyXML":"<body>Apple in London</body>"}`), "tid_test", SuggestionOptions{IncludeDetails: true})
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{
+			Concept:    apple,
+			OriginalID: "http://www.ft.com/thing/source-apple-uuid",
+			Details: &ConceptDetails{
+				Aliases:        []string{"Apple Inc", "Apple Computer"},
+				DescriptionXML: "<p>Maker of the iPhone</p>",
+				Ticker:         "AAPL",
+				FIGI:           "BBG000B9XRY4",
+			},
+		},
+		{Concept: london},
+	}, response.Suggestions)
+	// the details come with the broader concepts lookup
+	expect.Equal(int32(1), atomic.LoadInt32(&thingsCalls))
+
+	response, err = aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>Apple in London</body>"}`), "tid_test")
+	expect.NoError(err)
+	expect.Nil(response.Suggestions[0].Details)
+}
+
+func TestAggregateSuggester_GetSuggestionsWithBlacklistEntries(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	paris := Concept{ID: "http://www.ft.com/thing/paris-uuid", PrefLabel: "Paris", Type: ontologyLocationType}
+	rome := Concept{ID: "http://www.ft.com/thing/rome-uuid", PrefLabel: "Rome", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: london}, {Concept: paris}, {Concept: rome}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"london-uuid": london, "paris-uuid": paris, "rome-uuid": rome}, suggester)
+	aggregateSuggester.Blacklister = NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", jsonResponder(`{"uuids":["rome-uuid"],"entries":[
+		{"uuid":"london-uuid","reason":"embargo","author":"editor","createdAt":"2020-01-01T00:00:00Z","expiresAt":"2020-01-02T00:00:00Z"},
+		{"uuid":"paris-uuid","reason":"legal","author":"editor","createdAt":"2020-01-01T00:00:00Z"}
+	]}`))
+	vetoes := metrics.GetOrRegisterCounter("blacklist.vetoes", metrics.DefaultRegistry).Count()
+	legalVetoes := metrics.GetOrRegisterCounter("blacklist.vetoes.legal", metrics.DefaultRegistry).Count()
+	unspecifiedVetoes := metrics.GetOrRegisterCounter("blacklist.vetoes.unspecified", metrics.DefaultRegistry).Count()
+	embargoVetoes := metrics.GetOrRegisterCounter("blacklist.vetoes.embargo", metrics.DefaultRegistry).Count()
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London, Paris and Rome</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	// the embargo on London has expired
+	expect.Equal([]Suggestion{{Concept: london}}, response.Suggestions)
+	expect.Equal(vetoes+2, metrics.GetOrRegisterCounter("blacklist.vetoes", metrics.DefaultRegistry).Count())
+	expect.Equal(legalVetoes+1, metrics.GetOrRegisterCounter("blacklist.vetoes.legal", metrics.DefaultRegistry).Count())
+	expect.Equal(unspecifiedVetoes+1, metrics.GetOrRegisterCounter("blacklist.vetoes.unspecified", metrics.DefaultRegistry).Count())
+	expect.Equal(embargoVetoes, metrics.GetOrRegisterCounter("blacklist.vetoes.embargo", metrics.DefaultRegistry).Count())
+}
+
+func TestVetoReason(t *testing.T) {
+	assert.Equal(t, "legal-hold", vetoReason(" Legal hold!"))
+	assert.Equal(t, "unspecified", vetoReason(""))
+}
+
+func TestAggregateSuggester_GetSuggestionsCountsDroppedSuggestions(t *testing.T) {
+	expect := assert.New(t)
+
+	paris := Concept{ID: "http://www.ft.com/thing/paris-uuid", PrefLabel: "Paris", Type: ontologyLocationType}
+	france := Concept{ID: "http://www.ft.com/thing/france-uuid", PrefLabel: "France", Type: ontologyLocationType}
+	rome := Concept{ID: "http://www.ft.com/thing/rome-uuid", PrefLabel: "Rome", Type: ontologyLocationType}
+	macron := Concept{ID: "http://www.ft.com/thing/macron-uuid", PrefLabel: "Emmanuel Macron", Type: ontologyPersonType}
+	suggester := &typeFilteringSuggester{stubSuggester: stubSuggester{suggestions: []Suggestion{
+		{Concept: paris}, {Concept: france}, {Concept: rome}, {Concept: macron},
+		{Concept: Concept{ID: "http://www.ft.com/thing/unknown-uuid"}},
+	}}, conceptType: ontologyLocationType}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"paris-uuid": paris, "france-uuid": france, "rome-uuid": rome, "macron-uuid": macron,
+	}, suggester)
+	aggregateSuggester.BroaderProvider.Client = jsonResponder(`{"things":{"paris-uuid":{"broaderConcepts":[
+		{"id":"http://www.ft.com/thing/france-uuid","prefLabel":"France"}
+	]}}}`)
+	aggregateSuggester.Blacklister = NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", jsonResponder(`{"uuids":["rome-uuid"]}`))
+
+	dropped := func(reason string) int64 {
+		return metrics.GetOrRegisterCounter("suggestions.dropped."+reason, metrics.DefaultRegistry).Count()
+	}
+	reasons := []string{dropReasonUnconcorded, dropReasonUntrustedType, dropReasonBroader, dropReasonBlacklisted}
+	counts := map[string]int64{}
+	for _, reason := range reasons {
+		counts[reason] = dropped(reason)
+	}
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>Macron in Paris, France and Rome</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: paris}}, response.Suggestions)
+	for _, reason := range reasons {
+		expect.Equal(counts[reason]+1, dropped(reason), reason)
+	}
+}
+
+type typeFilteringSuggester struct {
+	stubSuggester
+	conceptType string
+}
+
+func (s *typeFilteringSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	var filtered []Suggestion
+	for _, suggestion := range suggestions {
+		if suggestion.Type == s.conceptType {
+			filtered = append(filtered, suggestion)
+		}
+	}
+	return filtered
+}
+
+func TestAggregateSuggester_GetSuggestionsWithRules(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	property := Concept{ID: "http://www.ft.com/thing/property-uuid", PrefLabel: "Property", Type: ontologyTopicType}
+	rules, err := NewRulesSuggester([]SuggestionRule{
+		{Name: "property", Keywords: []string{"property"}, Concept: Concept{ID: "http://www.ft.com/thing/property-uuid"}},
+	})
+	require.NoError(t, err)
+	ontotext := &stubSuggester{name: "Ontotext", suggestions: []Suggestion{{Concept: london}, {Concept: property}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"london-uuid": london, "property-uuid": property}, rules, ontotext)
+	aggregateSuggester.Merger = NewSuggestionsMerger(nil)
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London <b>property</b> prices</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: property, Provenance: ProvenanceRule, Sources: []string{"Suggestion Rules", "Ontotext"}},
+		{Concept: london, Sources: []string{"Ontotext"}},
+	}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsBoostsRulesWithoutMerging(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	property := Concept{ID: "http://www.ft.com/thing/property-uuid", PrefLabel: "Property", Type: ontologyTopicType}
+	rules, err := NewRulesSuggester([]SuggestionRule{
+		{Name: "property", Keywords: []string{"property"}, Concept: Concept{ID: "http://www.ft.com/thing/property-uuid"}},
+	})
+	require.NoError(t, err)
+	ontotext := &stubSuggester{name: "Ontotext", suggestions: []Suggestion{{Concept: london}, {Concept: property}}}
+	// the rules come after the suggestion API
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"london-uuid": london, "property-uuid": property}, ontotext, rules)
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body>London <b>property</b> prices</body>"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: property},
+		{Concept: property, Provenance: ProvenanceRule},
+		{Concept: london},
+	}, response.Suggestions)
+}
+
+func TestAggregateSuggester_GetSuggestionsWithFallbackSuggester(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	gazetteer, err := NewGazetteerSuggester([]GazetteerEntry{{Concept: Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London"}}})
+	require.NoError(t, err)
+	ontotext := &failingSuggester{stubSuggester: stubSuggester{name: "Ontotext"}, err: errors.New("connection refused")}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"london-uuid": london}, NewFallbackSuggester(ontotext, gazetteer))
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	response, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london, Predicate: predicateMentions}}, response.Suggestions)
+	expect.Equal([]string{DegradedSuggesterFallback}, response.Degraded)
+	data, err := getXmlSuggestionRequestFromJson(payload)
+	require.NoError(t, err)
+	_, cached := aggregateSuggester.Cache.Get(payloadHash(data))
+	expect.False(cached)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Financial-Times/go-fthealth/v1_1"
+)
+
+type AnnotationsRetriever interface {
+	GetAnnotations(uuid string, tid string) ([]Annotation, error)
+	Check() v1_1.Check
+}
+
+type AnnotationsAPI struct {
+	baseURL       string
+	endpoint      string
+	client        Client
+	systemID      string
+	name          string
+	failureImpact string
+}
+
+type Annotation struct {
+	Predicate string   `json:"predicate,omitempty"`
+	ID        string   `json:"id"`
+	APIURL    string   `json:"apiUrl,omitempty"`
+	Type      string   `json:"type,omitempty"`
+	Types     []string `json:"types,omitempty"`
+	PrefLabel string   `json:"prefLabel,omitempty"`
+}
+
+// NewAnnotationsRetriever builds a retriever for the existing annotations of a content.
+// The endpoint may contain a {uuid} placeholder, otherwise the content UUID is appended to it.
+func NewAnnotationsRetriever(annotationsAPIBaseURL, annotationsEndpoint string, client Client) AnnotationsRetriever {
+	return &AnnotationsAPI{
+		baseURL:       annotationsAPIBaseURL,
+		endpoint:      annotationsEndpoint,
+		client:        client,
+		systemID:      "public-annotations-api",
+		name:          "public-annotations-api",
+		failureImpact: "Comparing suggestions against existing annotations will not work",
+	}
+}
+
+func (a *AnnotationsAPI) GetAnnotations(uuid string, tid string) ([]Annotation, error) {
+	endpoint := strings.Trim(a.endpoint, "/")
+	if strings.Contains(endpoint, "{uuid}") {
+		endpoint = strings.Replace(endpoint, "{uuid}", uuid, 1)
+	} else {
+		endpoint = endpoint + "/" + uuid
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", strings.TrimRight(a.baseURL, "/"), endpoint), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("User-Agent", "UPP public-suggestions-api")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Request-Id", tid)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		// content without annotations is reported as not found
+		return []Annotation{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned HTTP %v", a.name, resp.StatusCode)
+	}
+
+	var annotations []Annotation
+	err = json.Unmarshal(body, &annotations)
+	if err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+func (a *AnnotationsAPI) Check() v1_1.Check {
+	return v1_1.Check{
+		ID:               a.systemID,
+		BusinessImpact:   a.failureImpact,
+		Name:             fmt.Sprintf("%v Healthcheck", a.name),
+		PanicGuide:       PanicGuideURL + a.systemID,
+		Severity:         2,
+		TechnicalSummary: fmt.Sprintf("%v is not available", a.name),
+		Checker:          a.healthCheck,
+	}
+}
+
+func (a *AnnotationsAPI) healthCheck() (string, error) {
+	req, err := http.NewRequest("GET", a.baseURL+"/__gtg", nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("User-Agent", "UPP public-suggestions-api")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Health check returned a non-200 HTTP status: %v", resp.StatusCode)
+	}
+	return fmt.Sprintf("%v is healthy", a.name), nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAnnotationsAPI_GetAnnotationsSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "http://annotations-api/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/annotations" &&
+			req.Header.Get("X-Request-Id") == "tid_test"
+	})).Return(&http.Response{
+		Body: ioutil.NopCloser(strings.NewReader(`[{
+			"predicate": "http://www.ft.com/ontology/annotation/about",
+			"id": "http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc494",
+			"apiUrl": "http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc494",
+			"types": ["http://www.ft.com/ontology/core/Thing", "http://www.ft.com/ontology/Location"],
+			"prefLabel": "London"
+		}]`)),
+		StatusCode: http.StatusOK,
+	}, nil)
+
+	retriever := NewAnnotationsRetriever("http://annotations-api", "/content/{uuid}/annotations", mockClient)
+	annotations, err := retriever.GetAnnotations("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.NoError(err)
+	expect.Len(annotations, 1)
+	expect.Equal("http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc494", annotations[0].ID)
+	expect.Equal("http://www.ft.com/ontology/annotation/about", annotations[0].Predicate)
+	expect.Equal("London", annotations[0].PrefLabel)
+	mockClient.AssertExpectations(t)
+}
+
+func TestAnnotationsAPI_GetAnnotationsUUIDAppendedToEndpoint(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "http://annotations-api/annotations/9d5e441e-0b02-11e8-8eb7-42f857ea9f09"
+	})).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`[]`)),
+		StatusCode: http.StatusOK,
+	}, nil)
+
+	retriever := NewAnnotationsRetriever("http://annotations-api", "/annotations", mockClient)
+	annotations, err := retriever.GetAnnotations("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.NoError(err)
+	expect.Empty(annotations)
+	mockClient.AssertExpectations(t)
+}
+
+func TestAnnotationsAPI_GetAnnotationsNotFoundIsEmpty(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`{"message":"No annotations found"}`)),
+		StatusCode: http.StatusNotFound,
+	}, nil)
+
+	retriever := NewAnnotationsRetriever("http://annotations-api", "/content/{uuid}/annotations", mockClient)
+	annotations, err := retriever.GetAnnotations("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.NoError(err)
+	expect.NotNil(annotations)
+	expect.Empty(annotations)
+}
+
+func TestAnnotationsAPI_GetAnnotationsUnexpectedStatus(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		StatusCode: http.StatusServiceUnavailable,
+	}, nil)
+
+	retriever := NewAnnotationsRetriever("http://annotations-api", "/content/{uuid}/annotations", mockClient)
+	annotations, err := retriever.GetAnnotations("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.EqualError(err, "public-annotations-api returned HTTP 503")
+	expect.Nil(annotations)
+}
+
+func TestAnnotationsAPI_CheckHealth(t *testing.T) {
+	expect := assert.New(t)
+	mockServer := new(mockSuggestionApiServer)
+	mockServer.On("GTG").Return(200).Once()
+	server := mockServer.startMockServer(t)
+	defer server.Close()
+
+	retriever := NewAnnotationsRetriever(server.URL, "/content/{uuid}/annotations", http.DefaultClient)
+	check := retriever.Check()
+	checkResult, err := check.Checker()
+
+	expect.Equal("public-annotations-api", check.ID)
+	expect.Equal("Comparing suggestions against existing annotations will not work", check.BusinessImpact)
+	expect.NoError(err)
+	expect.Equal("public-annotations-api is healthy", checkResult)
+	mock.AssertExpectationsForObjects(t, mockServer)
+}
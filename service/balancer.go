@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// BalancingPolicy is how a BalancingClient picks the endpoint of a request.
+type BalancingPolicy string
+
+const (
+	// BalancingPolicyRoundRobin picks the endpoints in turn.
+	BalancingPolicyRoundRobin BalancingPolicy = "round-robin"
+	// BalancingPolicyLeastOutstanding picks the endpoint with the fewest requests in progress, in turn between equals.
+	BalancingPolicyLeastOutstanding BalancingPolicy = "least-outstanding"
+)
+
+var ErrNoBalancedEndpoints = errors.New("no endpoints to balance requests over")
+
+func ParseBalancingPolicy(value string) (BalancingPolicy, error) {
+	switch p := BalancingPolicy(value); p {
+	case BalancingPolicyRoundRobin, BalancingPolicyLeastOutstanding:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid balancing policy %q, expected %v or %v", value, BalancingPolicyRoundRobin, BalancingPolicyLeastOutstanding)
+}
+
+// BalancingClient spreads the requests to a service over several endpoints. The requests are addressed to the first endpoint,
+// which is also the base URL given to the service, and are sent to the endpoint picked by the policy instead.
+// Requests failing to connect are retried on the other endpoints, and the endpoints failing repeatedly are ejected for a while.
+type BalancingClient struct {
+	// EjectionFailures is the number of consecutive failures, connection errors or 5xx responses, ejecting an endpoint. 0 disables ejection.
+	EjectionFailures int
+	// EjectionDuration is how long an ejected endpoint is left out, unless all the endpoints are.
+	EjectionDuration time.Duration
+
+	client    Client
+	policy    BalancingPolicy
+	base      *url.URL
+	endpoints []*balancedEndpoint
+	now       func() time.Time
+	failovers metrics.Counter
+	ejections metrics.Counter
+
+	mutex sync.Mutex
+	next  int
+}
+
+type balancedEndpoint struct {
+	url          *url.URL
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+}
+
+// NewBalancingClient builds a client balancing the requests over the given base URLs, recording its failovers and ejections in metrics named after the system.
+func NewBalancingClient(systemId string, baseURLs []string, policy BalancingPolicy, client Client) (*BalancingClient, error) {
+	b := &BalancingClient{
+		client:    client,
+		policy:    policy,
+		now:       time.Now,
+		failovers: metrics.GetOrRegisterCounter("balancing."+systemId+".failovers", metrics.DefaultRegistry),
+		ejections: metrics.GetOrRegisterCounter("balancing."+systemId+".ejections", metrics.DefaultRegistry),
+	}
+	for _, baseURL := range baseURLs {
+		u, err := url.Parse(strings.TrimSpace(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, expected an absolute URL", baseURL)
+		}
+		b.endpoints = append(b.endpoints, &balancedEndpoint{url: u})
+	}
+	if len(b.endpoints) == 0 {
+		return nil, ErrNoBalancedEndpoints
+	}
+	b.base = b.endpoints[0].url
+	return b, nil
+}
+
+// BaseURL is the base URL the service should address its requests to.
+func (b *BalancingClient) BaseURL() string {
+	return b.base.String()
+}
+
+func (b *BalancingClient) Do(req *http.Request) (*http.Response, error) {
+	if !b.balanced(req.URL) {
+		return b.client.Do(req)
+	}
+
+	tried := make(map[*balancedEndpoint]bool, len(b.endpoints))
+	for {
+		endpoint := b.pick(tried)
+		tried[endpoint] = true
+
+		attempt, err := b.rewrite(req, endpoint)
+		if err != nil {
+			b.release(endpoint, false)
+			return nil, err
+		}
+		resp, err := b.client.Do(attempt)
+		if req.Context().Err() != nil {
+			// the caller gave up on the request, e.g. a hedged request which lost, which tells nothing of the endpoint
+			b.release(endpoint, false)
+			return resp, err
+		}
+		b.release(endpoint, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		if err == nil {
+			return resp, nil
+		}
+
+		// only failed connections are retried, as the other endpoints wouldn't know about the failed attempt
+		if len(tried) == len(b.endpoints) || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		b.failovers.Inc(1)
+	}
+}
+
+func (b *BalancingClient) balanced(u *url.URL) bool {
+	return u.Scheme == b.base.Scheme && u.Host == b.base.Host && strings.HasPrefix(u.Path, b.base.Path)
+}
+
+// pick returns the endpoint for the next attempt among those which weren't tried, preferably among those which aren't ejected.
+func (b *BalancingClient) pick(tried map[*balancedEndpoint]bool) *balancedEndpoint {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	start := b.next
+	b.next = (b.next + 1) % len(b.endpoints)
+
+	var picked *balancedEndpoint
+	for _, ignoreEjection := range []bool{false, true} {
+		for i := range b.endpoints {
+			endpoint := b.endpoints[(start+i)%len(b.endpoints)]
+			if tried[endpoint] || (!ignoreEjection && now.Before(endpoint.ejectedUntil)) {
+				continue
+			}
+			if picked == nil || (b.policy == BalancingPolicyLeastOutstanding && endpoint.outstanding < picked.outstanding) {
+				picked = endpoint
+			}
+			if b.policy != BalancingPolicyLeastOutstanding {
+				break
+			}
+		}
+		if picked != nil {
+			break
+		}
+	}
+	picked.outstanding++
+	return picked
+}
+
+func (b *BalancingClient) release(endpoint *balancedEndpoint, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	endpoint.outstanding--
+	if !failed {
+		endpoint.failures = 0
+		return
+	}
+	endpoint.failures++
+	if b.EjectionFailures > 0 && endpoint.failures >= b.EjectionFailures {
+		endpoint.failures = 0
+		endpoint.ejectedUntil = b.now().Add(b.EjectionDuration)
+		b.ejections.Inc(1)
+	}
+}
+
+// rewrite addresses a copy of the request to the endpoint, with a fresh copy of its body.
+func (b *BalancingClient) rewrite(req *http.Request, endpoint *balancedEndpoint) (*http.Request, error) {
+	attempt := req.Clone(req.Context())
+	u := *req.URL
+	u.Scheme = endpoint.url.Scheme
+	u.Host = endpoint.url.Host
+	u.Path = endpoint.url.Path + strings.TrimPrefix(req.URL.Path, b.base.Path)
+	u.RawPath = ""
+	attempt.URL = &u
+	attempt.Host = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attempt.Body = body
+	}
+	return attempt, nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingClient records the hosts of the requests, failing to connect to the down hosts and answering 503 from the unavailable ones.
+type recordingClient struct {
+	mutex       sync.Mutex
+	hosts       []string
+	bodies      []string
+	down        map[string]bool
+	unavailable map[string]bool
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hosts = append(c.hosts, req.URL.Host+req.URL.Path)
+	if req.Body != nil {
+		body, _ := ioutil.ReadAll(req.Body)
+		c.bodies = append(c.bodies, string(body))
+	}
+	if c.down[req.URL.Host] {
+		return nil, errors.New("connection refused")
+	}
+	status := http.StatusOK
+	if c.unavailable[req.URL.Host] {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{Body: ioutil.NopCloser(strings.NewReader("")), StatusCode: status}, nil
+}
+
+func newTestBalancingClient(t *testing.T, systemId string, policy BalancingPolicy, client Client) *BalancingClient {
+	for _, metric := range []string{"failovers", "ejections"} {
+		metrics.DefaultRegistry.Unregister("balancing." + systemId + "." + metric)
+	}
+	b, err := NewBalancingClient(systemId, []string{"http://a:8080", "http://b:8080/prefix", " http://c:8080 "}, policy, client)
+	require.NoError(t, err)
+	return b
+}
+
+func TestBalancingClient_RoundRobin(t *testing.T) {
+	expect := assert.New(t)
+	client := &recordingClient{}
+	b := newTestBalancingClient(t, "round-robin", BalancingPolicyRoundRobin, client)
+	expect.Equal("http://a:8080", b.BaseURL())
+
+	for i := 0; i < 4; i++ {
+		req, err := http.NewRequest("GET", b.BaseURL()+"/things?uuid=1", nil)
+		require.NoError(t, err)
+		resp, err := b.Do(req)
+		require.NoError(t, err)
+		expect.Equal(http.StatusOK, resp.StatusCode)
+	}
+	// requests to other services aren't balanced
+	req, err := http.NewRequest("GET", "http://other:8080/things", nil)
+	require.NoError(t, err)
+	_, err = b.Do(req)
+	require.NoError(t, err)
+
+	expect.Equal([]string{"a:8080/things", "b:8080/prefix/things", "c:8080/things", "a:8080/things", "other:8080/things"}, client.hosts)
+}
+
+func TestBalancingClient_LeastOutstanding(t *testing.T) {
+	expect := assert.New(t)
+	b := newTestBalancingClient(t, "least-outstanding", BalancingPolicyLeastOutstanding, &recordingClient{})
+
+	first := b.pick(map[*balancedEndpoint]bool{})
+	second := b.pick(map[*balancedEndpoint]bool{})
+	third := b.pick(map[*balancedEndpoint]bool{})
+	expect.ElementsMatch(b.endpoints, []*balancedEndpoint{first, second, third})
+
+	b.release(second, false)
+	expect.Equal(second, b.pick(map[*balancedEndpoint]bool{}))
+}
+
+func TestBalancingClient_FailoverOnConnectionErrors(t *testing.T) {
+	expect := assert.New(t)
+	client := &recordingClient{down: map[string]bool{"a:8080": true, "b:8080": true}}
+	b := newTestBalancingClient(t, "failover", BalancingPolicyRoundRobin, client)
+
+	req, err := http.NewRequest("POST", b.BaseURL()+"/suggest", bytes.NewReader([]byte(`{"bodyXML":"text"}`)))
+	require.NoError(t, err)
+	resp, err := b.Do(req)
+
+	require.NoError(t, err)
+	expect.Equal(http.StatusOK, resp.StatusCode)
+	expect.Equal([]string{"a:8080/suggest", "b:8080/prefix/suggest", "c:8080/suggest"}, client.hosts)
+	// every attempt sends the whole body
+	expect.Equal([]string{`{"bodyXML":"text"}`, `{"bodyXML":"text"}`, `{"bodyXML":"text"}`}, client.bodies)
+	expect.Equal(int64(2), b.failovers.Count())
+}
+
+func TestBalancingClient_NoFailoverOnErrorResponses(t *testing.T) {
+	expect := assert.New(t)
+	client := &recordingClient{unavailable: map[string]bool{"a:8080": true}}
+	b := newTestBalancingClient(t, "no-failover", BalancingPolicyRoundRobin, client)
+
+	req, err := http.NewRequest("GET", b.BaseURL()+"/things", nil)
+	require.NoError(t, err)
+	resp, err := b.Do(req)
+
+	require.NoError(t, err)
+	expect.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	expect.Equal([]string{"a:8080/things"}, client.hosts)
+}
+
+func TestBalancingClient_AllEndpointsDown(t *testing.T) {
+	client := &recordingClient{down: map[string]bool{"a:8080": true, "b:8080": true, "c:8080": true}}
+	b := newTestBalancingClient(t, "all-down", BalancingPolicyRoundRobin, client)
+
+	req, err := http.NewRequest("GET", b.BaseURL()+"/things", nil)
+	require.NoError(t, err)
+	_, err = b.Do(req)
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Len(t, client.hosts, 3)
+}
+
+func TestBalancingClient_Ejection(t *testing.T) {
+	expect := assert.New(t)
+	client := &recordingClient{unavailable: map[string]bool{"a:8080": true}}
+	b := newTestBalancingClient(t, "ejection", BalancingPolicyRoundRobin, client)
+	b.EjectionFailures = 2
+	b.EjectionDuration = time.Minute
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	send := func() {
+		req, err := http.NewRequest("GET", b.BaseURL()+"/things", nil)
+		require.NoError(t, err)
+		_, err = b.Do(req)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 9; i++ {
+		send()
+	}
+	// a is ejected after its second failure, on the 4th request
+	expect.Equal([]string{"a:8080/things", "b:8080/prefix/things", "c:8080/things", "a:8080/things", "b:8080/prefix/things", "c:8080/things",
+		"b:8080/prefix/things", "b:8080/prefix/things", "c:8080/things"}, client.hosts)
+	expect.Equal(int64(1), b.ejections.Count())
+
+	client.hosts = nil
+	now = now.Add(time.Minute)
+	send()
+	expect.Equal([]string{"a:8080/things"}, client.hosts)
+}
+
+func TestBalancingClient_CancelledRequestsDontEject(t *testing.T) {
+	expect := assert.New(t)
+	client := &recordingClient{}
+	b := newTestBalancingClient(t, "cancelled", BalancingPolicyRoundRobin, stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		client.Do(req)
+		return nil, req.Context().Err()
+	}))
+	b.EjectionFailures = 1
+	b.EjectionDuration = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", b.BaseURL()+"/things", nil)
+		require.NoError(t, err)
+		_, err = b.Do(req)
+		expect.Equal(context.Canceled, err)
+	}
+
+	// neither failed over nor ejected
+	expect.Equal([]string{"a:8080/things", "b:8080/prefix/things", "c:8080/things"}, client.hosts)
+	expect.Equal(int64(0), b.ejections.Count())
+	expect.Equal(int64(0), b.failovers.Count())
+	client.hosts = nil
+	b.client = client
+	req, err := http.NewRequest("GET", b.BaseURL()+"/things", nil)
+	require.NoError(t, err)
+	_, err = b.Do(req)
+	require.NoError(t, err)
+	expect.Equal([]string{"a:8080/things"}, client.hosts)
+}
+
+func TestNewBalancingClient_InvalidEndpoints(t *testing.T) {
+	_, err := NewBalancingClient("invalid", []string{"http://a:8080", "b:8080"}, BalancingPolicyRoundRobin, &recordingClient{})
+	assert.EqualError(t, err, `invalid endpoint "b:8080", expected an absolute URL`)
+
+	_, err = NewBalancingClient("invalid", nil, BalancingPolicyRoundRobin, &recordingClient{})
+	assert.Equal(t, ErrNoBalancedEndpoints, err)
+}
+
+func TestParseBalancingPolicy(t *testing.T) {
+	policy, err := ParseBalancingPolicy("least-outstanding")
+	assert.NoError(t, err)
+	assert.Equal(t, BalancingPolicyLeastOutstanding, policy)
+
+	_, err = ParseBalancingPolicy("random")
+	assert.Error(t, err)
+}
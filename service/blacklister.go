@@ -6,8 +6,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-fthealth/v1_1"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
 )
 
 type ConceptBlacklister interface {
@@ -23,10 +25,45 @@ type Blacklister struct {
 	systemID      string
 	name          string
 	failureImpact string
+	inFlight      flightGroup
 }
 
+// Blacklist vetoes the concepts of its UUIDs permanently, and those of its entries until they expire.
 type Blacklist struct {
-	UUIDS []string `json:"uuids"`
+	UUIDS   []string         `json:"uuids"`
+	Entries []BlacklistEntry `json:"entries,omitempty"`
+}
+
+// BlacklistEntry records why, by whom and until when a concept is vetoed.
+type BlacklistEntry struct {
+	UUID      string     `json:"uuid"`
+	Reason    string     `json:"reason"`
+	Author    string     `json:"author,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (e BlacklistEntry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// Entry returns the unexpired entry vetoing the concept, if any. Plain UUIDs give an entry without reason.
+func (bl Blacklist) Entry(conceptId string, now time.Time) (BlacklistEntry, bool) {
+	for _, entry := range bl.Entries {
+		if strings.Contains(conceptId, entry.UUID) && !entry.expired(now) {
+			return entry, true
+		}
+	}
+	for _, uuid := range bl.UUIDS {
+		if strings.Contains(conceptId, uuid) {
+			return BlacklistEntry{UUID: uuid}, true
+		}
+	}
+	return BlacklistEntry{}, false
+}
+
+func (bl Blacklist) size() int {
+	return len(bl.UUIDS) + len(bl.Entries)
 }
 
 func NewConceptBlacklister(baseUrl string, endpoint string, client Client) ConceptBlacklister {
@@ -41,15 +78,19 @@ func NewConceptBlacklister(baseUrl string, endpoint string, client Client) Conce
 }
 
 func (b *Blacklister) IsBlacklisted(conceptId string, bl Blacklist) bool {
-	for _, uuid := range bl.UUIDS {
-		if strings.Contains(conceptId, uuid) {
-			return true
-		}
-	}
-	return false
+	_, ok := bl.Entry(conceptId, time.Now())
+	return ok
 }
 
 func (b *Blacklister) GetBlacklist(tid string) (Blacklist, error) {
+	result, err, _ := b.inFlight.do("blacklist", func() (interface{}, error) {
+		return b.requestBlacklist(tid)
+	})
+	blacklist, _ := result.(Blacklist)
+	return blacklist, err
+}
+
+func (b *Blacklister) requestBlacklist(tid string) (Blacklist, error) {
 	req, err := http.NewRequest("GET", b.baseUrl+b.endpoint, nil)
 	if err != nil {
 		return Blacklist{}, err
@@ -82,6 +123,18 @@ func (b *Blacklister) GetBlacklist(tid string) (Blacklist, error) {
 	return blacklist, nil
 }
 
+// Probe retrieves the blacklist and validates the shape of the response.
+func (b *Blacklister) Probe() (string, error) {
+	blacklist, err := b.GetBlacklist(tidutils.NewTransactionID())
+	if err != nil {
+		return "", err
+	}
+	if blacklist.UUIDS == nil && blacklist.Entries == nil {
+		return "", fmt.Errorf("%v returned a blacklist without uuids", b.name)
+	}
+	return fmt.Sprintf("%v returned %v blacklisted concepts", b.name, blacklist.size()), nil
+}
+
 func (b *Blacklister) Check() v1_1.Check {
 	return v1_1.Check{
 		ID:               b.systemID,
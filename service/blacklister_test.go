@@ -0,0 +1,92 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBlacklister_ProbeSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"uuids":["f758ef56-c40a-3162-91aa-3e8a3aabc495"]}`)), StatusCode: http.StatusOK}, nil)
+
+	blacklister := NewConceptBlacklister("http://test-url", "/blacklist", mockClient)
+	output, err := blacklister.(prober).Probe()
+
+	expect.NoError(err)
+	expect.Equal("concept-suggestions-blacklister returned 1 blacklisted concepts", output)
+}
+
+func TestBlacklister_ProbeInvalidResponse(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(strings.NewReader(`{}`)), StatusCode: http.StatusOK}, nil)
+
+	blacklister := NewConceptBlacklister("http://test-url", "/blacklist", mockClient)
+	output, err := blacklister.(prober).Probe()
+
+	expect.Empty(output)
+	expect.EqualError(err, "concept-suggestions-blacklister returned a blacklist without uuids")
+}
+
+func TestBlacklist_Entry(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Hour)
+	blacklist := Blacklist{
+		UUIDS: []string{"plain-uuid"},
+		Entries: []BlacklistEntry{
+			{UUID: "permanent-uuid", Reason: "legal"},
+			{UUID: "embargoed-uuid", Reason: "embargo", ExpiresAt: &expiry},
+		},
+	}
+
+	entry, ok := blacklist.Entry("http://www.ft.com/thing/plain-uuid", now)
+	expect.True(ok)
+	expect.Equal(BlacklistEntry{UUID: "plain-uuid"}, entry)
+
+	entry, ok = blacklist.Entry("http://www.ft.com/thing/permanent-uuid", now)
+	expect.True(ok)
+	expect.Equal("legal", entry.Reason)
+
+	entry, ok = blacklist.Entry("http://www.ft.com/thing/embargoed-uuid", now)
+	expect.True(ok)
+	expect.Equal("embargo", entry.Reason)
+
+	_, ok = blacklist.Entry("http://www.ft.com/thing/embargoed-uuid", expiry)
+	expect.False(ok)
+
+	_, ok = blacklist.Entry("http://www.ft.com/thing/other-uuid", now)
+	expect.False(ok)
+}
+
+func TestBlacklister_GetBlacklistWithEntries(t *testing.T) {
+	expect := assert.New(t)
+	blacklister := NewConceptBlacklister("http://test-url", "/blacklist", jsonResponder(`{"uuids":[],"entries":[{"uuid":"expired-uuid","reason":"embargo","createdAt":"2020-01-01T00:00:00Z","expiresAt":"2020-01-02T00:00:00Z"}]}`))
+	blacklist, err := blacklister.GetBlacklist("tid_test")
+
+	expect.NoError(err)
+	expect.Len(blacklist.Entries, 1)
+	expect.False(blacklister.IsBlacklisted("http://www.ft.com/thing/expired-uuid", blacklist))
+
+	output, err := blacklister.(prober).Probe()
+	expect.NoError(err)
+	expect.Equal("concept-suggestions-blacklister returned 1 blacklisted concepts", output)
+}
+
+func TestBlacklister_GetBlacklistRecoversPanics(t *testing.T) {
+	expect := assert.New(t)
+	blacklister := NewConceptBlacklister("http://test-url", "/blacklist", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		panic("client panicked")
+	}))
+
+	blacklist, err := blacklister.GetBlacklist("tid_test")
+	expect.EqualError(err, "in-flight call panicked: client panicked")
+	expect.Empty(blacklist.UUIDS)
+}
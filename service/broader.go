@@ -6,18 +6,123 @@ import (
 	"io/ioutil"
 	"net/http"
 	fp "path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Financial-Times/go-fthealth/v1_1"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
 )
 
+type BroaderPolicy string
+
+const (
+	// BroaderPolicyExclude drops the suggestions which are broader than other suggestions.
+	BroaderPolicyExclude BroaderPolicy = "exclude"
+	// BroaderPolicyAnnotate keeps them, listing the suggestions they are broader than in broaderOf.
+	BroaderPolicyAnnotate BroaderPolicy = "annotate"
+	// BroaderPolicyDemote keeps and annotates them, but moves them after all the other suggestions.
+	BroaderPolicyDemote BroaderPolicy = "demote"
+)
+
+type BroaderDepth string
+
+const (
+	// BroaderDepthDirect only considers the direct broader concepts of the suggestions.
+	BroaderDepthDirect BroaderDepth = "direct"
+	// BroaderDepthTransitive considers all the ancestors of the suggestions.
+	BroaderDepthTransitive BroaderDepth = "transitive"
+)
+
+func ParseBroaderPolicy(value string) (BroaderPolicy, error) {
+	switch policy := BroaderPolicy(value); policy {
+	case BroaderPolicyExclude, BroaderPolicyAnnotate, BroaderPolicyDemote:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown broader concepts policy %q", value)
+}
+
+func ParseBroaderDepth(value string) (BroaderDepth, error) {
+	switch depth := BroaderDepth(value); depth {
+	case BroaderDepthDirect, BroaderDepthTransitive:
+		return depth, nil
+	}
+	return "", fmt.Errorf("unknown broader concepts depth %q", value)
+}
+
+// ParseBroaderTypePolicies parses policies given per concept type as type=policy.
+func ParseBroaderTypePolicies(values []string) (map[string]BroaderPolicy, error) {
+	policies := make(map[string]BroaderPolicy, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("broader concepts policy %q is not of the form type=policy", value)
+		}
+		policy, err := ParseBroaderPolicy(value[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		policies[value[:i]] = policy
+	}
+	return policies, nil
+}
+
+// BroaderConceptsPolicy decides what happens to the suggestions which are broader than other suggestions.
+// The zero value excludes them, considering all their ancestors.
+type BroaderConceptsPolicy struct {
+	Default BroaderPolicy
+	ByType  map[string]BroaderPolicy
+	Depth   BroaderDepth
+}
+
+// WithOverrides returns the policy with the non-empty policy and depth of a single request applied to every concept type.
+func (p BroaderConceptsPolicy) WithOverrides(policy BroaderPolicy, depth BroaderDepth) BroaderConceptsPolicy {
+	if policy != "" {
+		p.Default, p.ByType = policy, nil
+	}
+	if depth != "" {
+		p.Depth = depth
+	}
+	return p
+}
+
+func (p BroaderConceptsPolicy) forType(conceptType string) BroaderPolicy {
+	if policy, ok := p.ByType[conceptType]; ok {
+		return policy
+	}
+	if p.Default == "" {
+		return BroaderPolicyExclude
+	}
+	return p.Default
+}
+
+func (p BroaderConceptsPolicy) depth() BroaderDepth {
+	if p.Depth == "" {
+		return BroaderDepthTransitive
+	}
+	return p.Depth
+}
+
+// ProvenanceImplied labels the suggestions which weren't made by a suggester, but are implied by broader relationships.
+const ProvenanceImplied = "implied"
+
+// ImpliedConceptsPolicy selects the broader concepts which are suggested as implied by the suggestions.
+type ImpliedConceptsPolicy struct {
+	// Types are the types of the suggestions whose broader concepts are implied. No types disables the implied suggestions.
+	Types []string
+	// Depth is the number of broader levels implied above a suggestion.
+	Depth int
+}
+
 type BroaderConceptsProvider struct {
 	systemID             string
 	name                 string
 	PublicThingsBaseURL  string
 	PublicThingsEndpoint string
 	Client               Client
+	Policy               BroaderConceptsPolicy
+	Implied              ImpliedConceptsPolicy
 	failureImpact        string
+	inFlight             flightGroup
 }
 
 func NewBroaderConceptsProvider(publicThingsAPIBaseURL, publicThingsEndpoint string, client Client) *BroaderConceptsProvider {
@@ -25,6 +130,7 @@ func NewBroaderConceptsProvider(publicThingsAPIBaseURL, publicThingsEndpoint str
 		PublicThingsBaseURL:  publicThingsAPIBaseURL,
 		PublicThingsEndpoint: publicThingsEndpoint,
 		Client:               client,
+		Policy:               BroaderConceptsPolicy{Default: BroaderPolicyExclude, Depth: BroaderDepthTransitive},
 		name:                 "public-things-api",
 		systemID:             "public-things-api",
 		failureImpact:        "Excluding broader concepts will not work",
@@ -36,12 +142,29 @@ type broaderResponse struct {
 }
 
 type Thing struct {
-	ID              string           `json:"id"`
+	ID string `json:"id"`
+	ConceptDetails
 	BroaderConcepts []BroaderConcept `json:"broaderConcepts"`
 }
 
+// ConceptDetails help editors tell apart similarly named concepts.
+type ConceptDetails struct {
+	Aliases        []string `json:"aliases,omitempty"`
+	DescriptionXML string   `json:"descriptionXML,omitempty"`
+	ImageURL       string   `json:"imageUrl,omitempty"`
+	Ticker         string   `json:"ticker,omitempty"`
+	FIGI           string   `json:"figiCode,omitempty"`
+}
+
+func (d ConceptDetails) isEmpty() bool {
+	return len(d.Aliases) == 0 && d.DescriptionXML == "" && d.ImageURL == "" && d.Ticker == "" && d.FIGI == ""
+}
+
 type BroaderConcept struct {
-	ID string `json:"id"`
+	ID        string   `json:"id"`
+	APIURL    string   `json:"apiUrl"`
+	PrefLabel string   `json:"prefLabel"`
+	Types     []string `json:"types"`
 }
 
 func (b *BroaderConceptsProvider) Check() v1_1.Check {
@@ -77,39 +200,50 @@ func (b *BroaderConceptsProvider) healthCheck() (string, error) {
 	return fmt.Sprintf("%v is healthy", b.name), nil
 }
 
-func (b *BroaderConceptsProvider) excludeBroaderConceptsFromResponse(suggestions map[int][]Suggestion, tid string) (map[int][]Suggestion, error) {
-	var ids []string
-	for _, sourceSuggestions := range suggestions {
-		for _, suggestion := range sourceSuggestions {
-			ids = append(ids, fp.Base(suggestion.ID))
-		}
+// Probe requests the broader concepts of a known concept and validates the shape of the response.
+func (b *BroaderConceptsProvider) Probe() (string, error) {
+	broader, err := b.getBroaderConcepts([]string{HealthcheckConceptUUID}, BroaderDepthTransitive, tidutils.NewTransactionID())
+	if err != nil {
+		return "", err
+	}
+	if broader.Things == nil {
+		return "", fmt.Errorf("%v returned a response without things", b.name)
 	}
+	return fmt.Sprintf("%v returned %v things for concept %v", b.name, len(broader.Things), HealthcheckConceptUUID), nil
+}
 
+// applyBroaderPolicy excludes, annotates or demotes the suggestions which are broader than other suggestions, as the policy says.
+// Things already in the index are reused, so only the suggestions it hasn't been asked about yet are looked up.
+// Demoting is left to the caller, as it reorders the suggestions of all the suggesters.
+func (b *BroaderConceptsProvider) applyBroaderPolicy(suggestions map[int][]Suggestion, index *broaderIndex, policy BroaderConceptsPolicy, tid string) (map[int][]Suggestion, error) {
+	ids := suggestionIDs(suggestions)
 	if len(ids) == 0 {
 		return suggestions, nil
 	}
 
-	results := make(map[int][]Suggestion)
-	broader, err := b.getBroaderConcepts(ids, tid)
-	if err != nil {
-		return suggestions, err
-	}
-
-	broaderConceptsChecker := make(map[string]bool)
-	for _, thing := range broader.Things {
-		for _, broaderConcept := range thing.BroaderConcepts {
-			broaderConceptsChecker[fp.Base(broaderConcept.ID)] = true
+	if missing := index.missing(ids); len(missing) > 0 {
+		broader, err := b.getBroaderConcepts(missing, policy.depth(), tid)
+		if err != nil {
+			return suggestions, err
 		}
+		index.add(missing, broader)
 	}
-	if len(broaderConceptsChecker) == 0 {
+
+	narrower := index.narrowerOf(suggestions)
+	if len(narrower) == 0 {
 		return suggestions, nil
 	}
 
+	results := make(map[int][]Suggestion)
 	for mapIdx, sourceSuggestions := range suggestions {
 		filteredSourceSuggestions := []Suggestion{}
 		for _, suggestion := range sourceSuggestions {
-			if broaderConceptsChecker[fp.Base(suggestion.ID)] {
-				continue
+			narrowerIDs, ok := narrower[fp.Base(suggestion.ID)]
+			if ok {
+				if policy.forType(suggestion.Type) == BroaderPolicyExclude {
+					continue
+				}
+				suggestion.BroaderOf = narrowerIDs
 			}
 			filteredSourceSuggestions = append(filteredSourceSuggestions, suggestion)
 		}
@@ -119,7 +253,188 @@ func (b *BroaderConceptsProvider) excludeBroaderConceptsFromResponse(suggestions
 	return results, nil
 }
 
-func (b *BroaderConceptsProvider) getBroaderConcepts(ids []string, tid string) (*broaderResponse, error) {
+// demoteBroaderConcepts moves the annotated broader suggestions whose policy is to be demoted after all the other suggestions.
+func demoteBroaderConcepts(suggestions []Suggestion, policy BroaderConceptsPolicy) []Suggestion {
+	var demoted []Suggestion
+	kept := suggestions[:0:0]
+	for _, suggestion := range suggestions {
+		if len(suggestion.BroaderOf) > 0 && policy.forType(suggestion.Type) == BroaderPolicyDemote {
+			demoted = append(demoted, suggestion)
+			continue
+		}
+		kept = append(kept, suggestion)
+	}
+	return append(kept, demoted...)
+}
+
+// impliedSuggestions returns the broader concepts of the suggestions of the implied types, up to the implied depth,
+// as suggestions labelled as implied and listing the suggestions implying them.
+// Concepts which are already suggested aren't implied again, nor are the excluded ones, keyed by UUID.
+func (b *BroaderConceptsProvider) impliedSuggestions(suggestions []Suggestion, excluded map[string]bool, tid string) ([]Suggestion, error) {
+	suggested := make(map[string]bool)
+	for id := range excluded {
+		suggested[id] = true
+	}
+	impliedBy := make(map[string][]string)
+	types := make(map[string]string)
+	var frontier []string
+	for _, suggestion := range suggestions {
+		id := fp.Base(suggestion.ID)
+		suggested[id] = true
+		if contains(b.Implied.Types, suggestion.Type) {
+			frontier = append(frontier, id)
+			impliedBy[id] = append(impliedBy[id], suggestion.ID)
+			types[id] = suggestion.Type
+		}
+	}
+
+	var implied []Suggestion
+	positions := make(map[string]int)
+	for depth := 0; depth < b.Implied.Depth && len(frontier) > 0; depth++ {
+		frontier = dedup(frontier)
+		broader, err := b.getBroaderConcepts(frontier, BroaderDepthDirect, tid)
+		if err != nil {
+			return implied, err
+		}
+		index := newBroaderIndex()
+		index.add(frontier, broader)
+
+		var next []string
+		for _, id := range frontier {
+			for _, broaderConcept := range index.things[id].BroaderConcepts {
+				broaderID := fp.Base(broaderConcept.ID)
+				if suggested[broaderID] || broaderID == id {
+					continue
+				}
+				impliedBy[broaderID] = unionSources(impliedBy[broaderID], impliedBy[id])
+				if _, ok := positions[broaderID]; ok {
+					continue
+				}
+
+				conceptType := types[id]
+				if len(broaderConcept.Types) > 0 {
+					conceptType = broaderConcept.Types[len(broaderConcept.Types)-1]
+				}
+				types[broaderID] = conceptType
+				positions[broaderID] = len(implied)
+				implied = append(implied, Suggestion{
+					Concept: Concept{
+						ID:        broaderConcept.ID,
+						APIURL:    broaderConcept.APIURL,
+						PrefLabel: broaderConcept.PrefLabel,
+						Type:      conceptType,
+					},
+					Provenance: ProvenanceImplied,
+				})
+				next = append(next, broaderID)
+			}
+		}
+		frontier = next
+	}
+
+	for broaderID, pos := range positions {
+		implied[pos].ImpliedBy = impliedBy[broaderID]
+	}
+	return implied, nil
+}
+
+// broaderIndex keeps the things returned by the public things API by the UUID they were requested with and by their own UUID,
+// so a lookup made with source UUIDs can be reused for the concorded suggestions.
+type broaderIndex struct {
+	requested map[string]bool
+	things    map[string]Thing
+}
+
+func newBroaderIndex() *broaderIndex {
+	return &broaderIndex{
+		requested: map[string]bool{},
+		things:    map[string]Thing{},
+	}
+}
+
+func (i *broaderIndex) add(ids []string, broader *broaderResponse) {
+	for _, id := range ids {
+		i.requested[id] = true
+	}
+	for key, thing := range broader.Things {
+		i.things[fp.Base(key)] = thing
+		if thing.ID != "" {
+			i.things[fp.Base(thing.ID)] = thing
+		}
+	}
+}
+
+// missing returns the UUIDs which have neither been requested nor returned as a thing.
+func (i *broaderIndex) missing(ids []string) []string {
+	var missing []string
+	for _, id := range ids {
+		if _, ok := i.things[id]; !ok && !i.requested[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// narrowerOf maps the UUIDs of the broader concepts of the suggestions to the IDs of the suggestions they are broader than.
+func (i *broaderIndex) narrowerOf(suggestions map[int][]Suggestion) map[string][]string {
+	var sources []int
+	for mapIdx := range suggestions {
+		sources = append(sources, mapIdx)
+	}
+	// keep the narrower suggestions in the order of their suggesters
+	sort.Ints(sources)
+
+	narrower := make(map[string][]string)
+	for _, mapIdx := range sources {
+		for _, suggestion := range suggestions[mapIdx] {
+			id := fp.Base(suggestion.ID)
+			for _, broaderConcept := range i.things[id].BroaderConcepts {
+				broaderID := fp.Base(broaderConcept.ID)
+				if broaderID == id || contains(narrower[broaderID], suggestion.ID) {
+					continue
+				}
+				narrower[broaderID] = append(narrower[broaderID], suggestion.ID)
+			}
+		}
+	}
+	return narrower
+}
+
+// withDetails returns the suggestions with the details of their things attached, when the index has any.
+func (i *broaderIndex) withDetails(suggestions map[int][]Suggestion) map[int][]Suggestion {
+	results := make(map[int][]Suggestion, len(suggestions))
+	for mapIdx, sourceSuggestions := range suggestions {
+		results[mapIdx] = make([]Suggestion, 0, len(sourceSuggestions))
+		for _, suggestion := range sourceSuggestions {
+			if thing, ok := i.things[fp.Base(suggestion.ID)]; ok && !thing.ConceptDetails.isEmpty() {
+				details := thing.ConceptDetails
+				suggestion.Details = &details
+			}
+			results[mapIdx] = append(results[mapIdx], suggestion)
+		}
+	}
+	return results
+}
+
+func suggestionIDs(suggestions map[int][]Suggestion) []string {
+	var ids []string
+	for _, sourceSuggestions := range suggestions {
+		for _, suggestion := range sourceSuggestions {
+			ids = append(ids, fp.Base(suggestion.ID))
+		}
+	}
+	return dedup(ids)
+}
+
+func (b *BroaderConceptsProvider) getBroaderConcepts(ids []string, depth BroaderDepth, tid string) (*broaderResponse, error) {
+	result, err, _ := b.inFlight.do(string(depth)+":"+idsKey(ids), func() (interface{}, error) {
+		return b.requestBroaderConcepts(ids, depth, tid)
+	})
+	broader, _ := result.(*broaderResponse)
+	return broader, err
+}
+
+func (b *BroaderConceptsProvider) requestBroaderConcepts(ids []string, depth BroaderDepth, tid string) (*broaderResponse, error) {
 	var result broaderResponse
 	preparedURL := fmt.Sprintf("%s/%s", strings.TrimRight(b.PublicThingsBaseURL, "/"), strings.Trim(b.PublicThingsEndpoint, "/"))
 	req, err := http.NewRequest("GET", preparedURL, nil)
@@ -134,7 +449,9 @@ func (b *BroaderConceptsProvider) getBroaderConcepts(ids []string, tid string) (
 	}
 
 	queryParams.Add("showRelationship", "broader")
-	queryParams.Add("showRelationship", "broaderTransitive")
+	if depth != BroaderDepthDirect {
+		queryParams.Add("showRelationship", "broaderTransitive")
+	}
 
 	req.URL.RawQuery = queryParams.Encode()
 
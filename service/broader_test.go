@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -534,7 +535,7 @@ func TestBroaderService_excludeBroaderConcepts(t *testing.T) {
 
 		excludeService := NewBroaderConceptsProvider("dummyURL", "things", publicThingsMock)
 
-		res, err := excludeService.excludeBroaderConceptsFromResponse(testCase.suggestions, "test_tid")
+		res, err := excludeService.applyBroaderPolicy(testCase.suggestions, newBroaderIndex(), BroaderConceptsPolicy{Default: BroaderPolicyExclude}, "test_tid")
 		if err != nil {
 			ast.NotEmptyf(testCase.expectedErrorContains, "%s -> empty expected error", testCase.testName)
 			ast.Containsf(err.Error(), testCase.expectedErrorContains, "%s -> not expected error returned", testCase.testName)
@@ -561,3 +562,233 @@ func TestBroaderService_excludeBroaderConcepts(t *testing.T) {
 		publicThingsMock.AssertExpectations(t)
 	}
 }
+
+func TestBroaderConceptsProvider_ProbeSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Query().Get("uuid") == HealthcheckConceptUUID
+	})).Return(&http.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte(`{"things":{"` + HealthcheckConceptUUID + `":{"id":"http://www.ft.com/thing/` + HealthcheckConceptUUID + `"}}}`))), StatusCode: http.StatusOK}, nil)
+
+	provider := NewBroaderConceptsProvider("http://test-url", "/things", mockClient)
+	output, err := provider.Probe()
+
+	expect.NoError(err)
+	expect.Equal("public-things-api returned 1 things for concept "+HealthcheckConceptUUID, output)
+	mockClient.AssertExpectations(t)
+}
+
+func TestBroaderConceptsProvider_ProbeInvalidResponse(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`))), StatusCode: http.StatusOK}, nil)
+
+	provider := NewBroaderConceptsProvider("http://test-url", "/things", mockClient)
+	output, err := provider.Probe()
+
+	expect.Empty(output)
+	expect.EqualError(err, "public-things-api returned a response without things")
+}
+
+func TestBroaderConceptsProvider_applyBroaderPolicyReusesIndex(t *testing.T) {
+	expect := assert.New(t)
+
+	var requested [][]string
+	provider := NewBroaderConceptsProvider("dummyURL", "things", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.Query()["uuid"])
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"things":{}}`)), StatusCode: http.StatusOK}, nil
+	}))
+
+	// looked up with a source UUID, returned under its canonical UUID
+	index := newBroaderIndex()
+	index.add([]string{"source-uuid", "no-broader-uuid"}, &broaderResponse{Things: map[string]Thing{
+		"source-uuid": {
+			ID:              "http://www.ft.com/thing/canonical-uuid",
+			BroaderConcepts: []BroaderConcept{{ID: "http://www.ft.com/thing/broader-uuid"}},
+		},
+	}})
+
+	suggestions := map[int][]Suggestion{
+		0: {
+			{Concept: Concept{ID: "http://www.ft.com/thing/canonical-uuid"}},
+			{Concept: Concept{ID: "http://www.ft.com/thing/broader-uuid"}},
+			{Concept: Concept{ID: "http://www.ft.com/thing/no-broader-uuid"}},
+		},
+	}
+	res, err := provider.applyBroaderPolicy(suggestions, index, BroaderConceptsPolicy{}, "test_tid")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: Concept{ID: "http://www.ft.com/thing/canonical-uuid"}},
+		{Concept: Concept{ID: "http://www.ft.com/thing/no-broader-uuid"}},
+	}, res[0])
+	// only the concept which was neither requested nor returned is looked up
+	expect.Equal([][]string{{"broader-uuid"}}, requested)
+}
+
+func TestBroaderConceptsProvider_applyBroaderPolicy(t *testing.T) {
+	europe := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/a1f96d3d-dd01-11e8-b32e-6c96cfdf3997", Type: ontologyLocationType}}
+	france := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997", Type: ontologyLocationType}}
+	company := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/c9e114c1-dd01-11e8-8d2b-6c96cfdf3997", Type: ontologyTopicType}}
+	apple := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/ca26a873-dd01-11e8-9a17-6c96cfdf3997", Type: ontologyOrganisationType}}
+	things := `{"things":{
+		"a1a2f869-dd01-11e8-abd7-6c96cfdf3997":{"broaderConcepts":[{"id":"` + europe.ID + `"}]},
+		"ca26a873-dd01-11e8-9a17-6c96cfdf3997":{"broaderConcepts":[{"id":"` + company.ID + `"}]}
+	}}`
+
+	annotated := func(s Suggestion, narrower ...Suggestion) Suggestion {
+		for _, n := range narrower {
+			s.BroaderOf = append(s.BroaderOf, n.ID)
+		}
+		return s
+	}
+
+	testCases := []struct {
+		name     string
+		policy   BroaderConceptsPolicy
+		expected map[int][]Suggestion
+	}{
+		{
+			name:     "excludeByDefault",
+			policy:   BroaderConceptsPolicy{},
+			expected: map[int][]Suggestion{0: {france}, 1: {apple}},
+		},
+		{
+			name:     "annotate",
+			policy:   BroaderConceptsPolicy{Default: BroaderPolicyAnnotate},
+			expected: map[int][]Suggestion{0: {annotated(europe, france), france}, 1: {annotated(company, apple), apple}},
+		},
+		{
+			name:     "policyByType",
+			policy:   BroaderConceptsPolicy{Default: BroaderPolicyExclude, ByType: map[string]BroaderPolicy{ontologyLocationType: BroaderPolicyDemote}},
+			expected: map[int][]Suggestion{0: {annotated(europe, france), france}, 1: {apple}},
+		},
+		{
+			name:     "requestOverridesPolicyByType",
+			policy:   BroaderConceptsPolicy{ByType: map[string]BroaderPolicy{ontologyLocationType: BroaderPolicyDemote}}.WithOverrides(BroaderPolicyExclude, ""),
+			expected: map[int][]Suggestion{0: {france}, 1: {apple}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			provider := NewBroaderConceptsProvider("dummyURL", "things", jsonResponder(things))
+			res, err := provider.applyBroaderPolicy(map[int][]Suggestion{0: {europe, france}, 1: {company, apple}}, newBroaderIndex(), testCase.policy, "test_tid")
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, res)
+		})
+	}
+}
+
+func TestBroaderConceptsProvider_applyBroaderPolicyDepth(t *testing.T) {
+	expect := assert.New(t)
+
+	var relationships []string
+	provider := NewBroaderConceptsProvider("dummyURL", "things", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		relationships = req.URL.Query()["showRelationship"]
+		return jsonResponder(`{"things":{}}`)(req)
+	}))
+	suggestions := map[int][]Suggestion{0: {{Concept: Concept{ID: "http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997"}}}}
+
+	_, err := provider.applyBroaderPolicy(suggestions, newBroaderIndex(), BroaderConceptsPolicy{Depth: BroaderDepthDirect}, "test_tid")
+	expect.NoError(err)
+	expect.Equal([]string{"broader"}, relationships)
+
+	_, err = provider.applyBroaderPolicy(suggestions, newBroaderIndex(), provider.Policy, "test_tid")
+	expect.NoError(err)
+	expect.Equal([]string{"broader", "broaderTransitive"}, relationships)
+}
+
+func TestDemoteBroaderConcepts(t *testing.T) {
+	europe := Suggestion{Concept: Concept{ID: "europe", Type: ontologyLocationType}, BroaderOf: []string{"france"}}
+	company := Suggestion{Concept: Concept{ID: "company", Type: ontologyTopicType}, BroaderOf: []string{"apple"}}
+	france := Suggestion{Concept: Concept{ID: "france", Type: ontologyLocationType}}
+	apple := Suggestion{Concept: Concept{ID: "apple", Type: ontologyOrganisationType}}
+	policy := BroaderConceptsPolicy{Default: BroaderPolicyAnnotate, ByType: map[string]BroaderPolicy{ontologyLocationType: BroaderPolicyDemote}}
+
+	assert.Equal(t, []Suggestion{company, france, apple, europe}, demoteBroaderConcepts([]Suggestion{europe, company, france, apple}, policy))
+}
+
+func TestParseBroaderTypePolicies(t *testing.T) {
+	expect := assert.New(t)
+
+	policies, err := ParseBroaderTypePolicies([]string{ontologyLocationType + "=annotate", ontologyTopicType + "=demote"})
+	expect.NoError(err)
+	expect.Equal(map[string]BroaderPolicy{ontologyLocationType: BroaderPolicyAnnotate, ontologyTopicType: BroaderPolicyDemote}, policies)
+
+	_, err = ParseBroaderTypePolicies([]string{ontologyLocationType})
+	expect.EqualError(err, `broader concepts policy "`+ontologyLocationType+`" is not of the form type=policy`)
+
+	_, err = ParseBroaderTypePolicies([]string{ontologyLocationType + "=hide"})
+	expect.EqualError(err, `unknown broader concepts policy "hide"`)
+}
+
+func TestBroaderConceptsProvider_impliedSuggestions(t *testing.T) {
+	expect := assert.New(t)
+
+	paris := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/paris-uuid", Type: ontologyLocationType}}
+	lyon := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/lyon-uuid", Type: ontologyLocationType}}
+	apple := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/apple-uuid", Type: ontologyOrganisationType}}
+	europe := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/europe-uuid", Type: ontologyLocationType}}
+
+	var requested [][]string
+	provider := NewBroaderConceptsProvider("dummyURL", "things", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.Query()["uuid"])
+		expect.Equal([]string{"broader"}, req.URL.Query()["showRelationship"])
+		return jsonResponder(`{"things":{
+			"paris-uuid":{"broaderConcepts":[{"id":"http://www.ft.com/thing/france-uuid","apiUrl":"http://api.ft.com/things/france-uuid","prefLabel":"France","types":["http://www.ft.com/ontology/core/Thing","http://www.ft.com/ontology/Location"]}]},
+			"lyon-uuid":{"broaderConcepts":[{"id":"http://www.ft.com/thing/france-uuid","prefLabel":"France"}]},
+			"apple-uuid":{"broaderConcepts":[{"id":"http://www.ft.com/thing/company-uuid"}]},
+			"france-uuid":{"broaderConcepts":[{"id":"http://www.ft.com/thing/europe-uuid"},{"id":"http://www.ft.com/thing/eu-uuid","prefLabel":"European Union"}]}
+		}}`)(req)
+	}))
+	provider.Implied = ImpliedConceptsPolicy{Types: []string{ontologyLocationType}, Depth: 2}
+
+	implied, err := provider.impliedSuggestions([]Suggestion{paris, apple, lyon, europe}, nil, "test_tid")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{
+			Concept:    Concept{ID: "http://www.ft.com/thing/france-uuid", APIURL: "http://api.ft.com/things/france-uuid", PrefLabel: "France", Type: ontologyLocationType},
+			Provenance: ProvenanceImplied,
+			ImpliedBy:  []string{paris.ID, lyon.ID},
+		},
+		{
+			Concept:    Concept{ID: "http://www.ft.com/thing/eu-uuid", PrefLabel: "European Union", Type: ontologyLocationType},
+			Provenance: ProvenanceImplied,
+			ImpliedBy:  []string{paris.ID, lyon.ID},
+		},
+	}, implied)
+	// europe is already suggested, so neither it nor its own broader concepts are implied
+	expect.Equal([][]string{{"paris-uuid", "lyon-uuid", "europe-uuid"}, {"france-uuid"}}, requested)
+}
+
+func TestBroaderConceptsProvider_impliedSuggestionsDepth(t *testing.T) {
+	expect := assert.New(t)
+
+	calls := 0
+	provider := NewBroaderConceptsProvider("dummyURL", "things", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return jsonResponder(`{"things":{"paris-uuid":{"broaderConcepts":[{"id":"http://www.ft.com/thing/france-uuid"}]}}}`)(req)
+	}))
+	provider.Implied = ImpliedConceptsPolicy{Types: []string{ontologyLocationType}, Depth: 1}
+
+	implied, err := provider.impliedSuggestions([]Suggestion{{Concept: Concept{ID: "http://www.ft.com/thing/paris-uuid", Type: ontologyLocationType}}}, nil, "test_tid")
+
+	expect.NoError(err)
+	expect.Len(implied, 1)
+	expect.Equal(1, calls)
+}
+
+func TestBroaderConceptsProvider_impliedSuggestionsError(t *testing.T) {
+	provider := NewBroaderConceptsProvider("dummyURL", "things", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader("")), StatusCode: http.StatusServiceUnavailable}, nil
+	}))
+	provider.Implied = ImpliedConceptsPolicy{Types: []string{ontologyLocationType}, Depth: 1}
+
+	implied, err := provider.impliedSuggestions([]Suggestion{{Concept: Concept{ID: "http://www.ft.com/thing/paris-uuid", Type: ontologyLocationType}}}, nil, "test_tid")
+
+	assert.EqualError(t, err, "non 200 status code returned: 503")
+	assert.Empty(t, implied)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	fp "path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+)
+
+var (
+	bylinePrefixRegex    = regexp.MustCompile(`(?i)^(additional reporting )?by\s+`)
+	bylineGroupRegex     = regexp.MustCompile(`\s*;\s*`)
+	bylineSeparatorRegex = regexp.MustCompile(`\s*[,&]\s*`)
+	bylineAndRegex       = regexp.MustCompile(`(?:^|\s+)and(?:\s+|$)`)
+	bylineLocationRegex  = regexp.MustCompile(`(?i)\s+in\s+`)
+)
+
+// BylineAuthor is an author named in a byline, with the location they reported from, if any.
+type BylineAuthor struct {
+	Name     string
+	Location string
+}
+
+// ParseByline splits an FT byline into its authors. A location applies to all the authors named since the previous location
+// within the same ;-separated group, so "Eric Platt in New York, Michael Hunter and Adam Samson in London" puts both Michael Hunter
+// and Adam Samson in London, while "Michael Hunter; Eric Platt in New York" doesn't locate Michael Hunter.
+// "and" only separates names, so locations such as Trinidad and Tobago are kept whole.
+func ParseByline(byline string) []BylineAuthor {
+	byline = bylinePrefixRegex.ReplaceAllString(strings.TrimSpace(byline), "")
+
+	var authors []BylineAuthor
+	for _, group := range bylineGroupRegex.Split(byline, -1) {
+		located := len(authors)
+		for _, segment := range bylineSeparatorRegex.Split(group, -1) {
+			for segment != "" {
+				in := bylineLocationRegex.FindStringIndex(segment)
+				if in == nil {
+					authors = appendBylineNames(authors, segment)
+					break
+				}
+				authors = appendBylineNames(authors, segment[:in[0]])
+				location, rest := splitBylineLocation(segment[in[1]:])
+				for ; located < len(authors); located++ {
+					authors[located].Location = location
+				}
+				segment = rest
+			}
+		}
+	}
+	return authors
+}
+
+// splitBylineLocation splits the text following an "in" into the location and the rest of the byline, which starts after the last "and"
+// preceding another "in", e.g. "Trinidad and Tobago and Michael Hunter in London" into Trinidad and Tobago, and Michael Hunter in London.
+func splitBylineLocation(text string) (string, string) {
+	next := bylineLocationRegex.FindStringIndex(text)
+	if next == nil {
+		return text, ""
+	}
+	ands := bylineAndRegex.FindAllStringIndex(text[:next[0]], -1)
+	if len(ands) == 0 {
+		return text, ""
+	}
+	last := ands[len(ands)-1]
+	return text[:last[0]], text[last[1]:]
+}
+
+func appendBylineNames(authors []BylineAuthor, names string) []BylineAuthor {
+	for _, name := range bylineAndRegex.Split(names, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			authors = append(authors, BylineAuthor{Name: name})
+		}
+	}
+	return authors
+}
+
+func normaliseName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// BylineAuthorsSuggester suggests the authors named in the byline of the content who are in a local staff list,
+// matched on their prefLabel or aliases regardless of case.
+type BylineAuthorsSuggester struct {
+	name  string
+	staff map[string]Concept
+	log   *logger.UPPLogger
+}
+
+// NewBylineAuthorsSuggester indexes the staff list, e.g. a snapshot of the FT authors. Its concepts are suggested as people.
+func NewBylineAuthorsSuggester(staff []GazetteerEntry, log *logger.UPPLogger) (*BylineAuthorsSuggester, error) {
+	index := make(map[string]Concept, len(staff))
+	for _, member := range staff {
+		if member.ID == "" {
+			return nil, fmt.Errorf("staff member %q has no concept id", member.PrefLabel)
+		}
+		concept := member.Concept
+		concept.Type = ontologyPersonType
+		concept.IsFTAuthor = true
+		for _, name := range append([]string{member.PrefLabel}, member.Aliases...) {
+			if name != "" {
+				index[normaliseName(name)] = concept
+			}
+		}
+	}
+	return &BylineAuthorsSuggester{name: "Byline Authors", staff: index, log: log}, nil
+}
+
+func (b *BylineAuthorsSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	var input JsonInput
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return SuggestionsResponse{}, err
+	}
+
+	response := SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	seen := make(map[string]bool)
+	for _, author := range ParseByline(input.Byline) {
+		concept, ok := b.staff[normaliseName(author.Name)]
+		if !ok || seen[concept.ID] {
+			continue
+		}
+		seen[concept.ID] = true
+		response.Suggestions = append(response.Suggestions, Suggestion{Concept: concept, Predicate: predicateHasAuthor})
+	}
+	return response, nil
+}
+
+func (b *BylineAuthorsSuggester) suggestsFromByline() bool {
+	return true
+}
+
+func (b *BylineAuthorsSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return suggestions
+}
+
+func (b *BylineAuthorsSuggester) GetName() string {
+	return b.name
+}
+
+// CrossChecked wraps an authors suggester so the authors it suggests are checked against the byline.
+// Authors of the byline which it didn't suggest, and suggested authors who aren't in the byline, are logged and counted but kept as suggested.
+func (b *BylineAuthorsSuggester) CrossChecked(authors Suggester) Suggester {
+	return &crossCheckedSuggester{Suggester: authors, byline: b}
+}
+
+type crossCheckedSuggester struct {
+	Suggester
+	byline *BylineAuthorsSuggester
+}
+
+func (c *crossCheckedSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	resp, err := c.Suggester.GetSuggestions(payload, tid)
+	if err != nil {
+		return resp, err
+	}
+	var input JsonInput
+	if json.Unmarshal(payload, &input) == nil {
+		c.byline.crossCheck(c.Suggester.GetName(), resp.Suggestions, ParseByline(input.Byline), tid)
+	}
+	return resp, err
+}
+
+func (c *crossCheckedSuggester) suggestsFromByline() bool {
+	return suggestsFromByline(c.Suggester)
+}
+
+func (b *BylineAuthorsSuggester) crossCheck(suggesterName string, suggestions []Suggestion, authors []BylineAuthor, tid string) {
+	logEntry := b.log.WithTransactionID(tid)
+
+	suggestedNames, suggestedIDs := make(map[string]bool), make(map[string]bool)
+	for _, suggestion := range suggestions {
+		if suggestion.Predicate == predicateHasAuthor {
+			suggestedNames[normaliseName(suggestion.PrefLabel)] = true
+			suggestedIDs[fp.Base(suggestion.ID)] = true
+		}
+	}
+
+	bylineNames, bylineIDs := make(map[string]bool), make(map[string]bool)
+	for _, author := range authors {
+		name := normaliseName(author.Name)
+		bylineNames[name] = true
+		concept, inStaff := b.staff[name]
+		if inStaff {
+			bylineIDs[fp.Base(concept.ID)] = true
+		}
+		if !suggestedNames[name] && !(inStaff && suggestedIDs[fp.Base(concept.ID)]) {
+			logEntry.Infof("Byline author %q wasn't suggested by %v", author.Name, suggesterName)
+			metrics.GetOrRegisterCounter("byline.crosscheck.unsuggested", metrics.DefaultRegistry).Inc(1)
+		}
+	}
+	for _, suggestion := range suggestions {
+		if suggestion.Predicate == predicateHasAuthor && !bylineNames[normaliseName(suggestion.PrefLabel)] && !bylineIDs[fp.Base(suggestion.ID)] {
+			logEntry.Infof("%v suggested author %q who isn't in the byline", suggesterName, suggestion.PrefLabel)
+			metrics.GetOrRegisterCounter("byline.crosscheck.unexpected", metrics.DefaultRegistry).Inc(1)
+		}
+	}
+}
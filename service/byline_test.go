@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ericPlatt     = Concept{ID: "http://www.ft.com/thing/eric-platt-uuid", PrefLabel: "Eric Platt"}
+	michaelHunter = Concept{ID: "http://www.ft.com/thing/michael-hunter-uuid", PrefLabel: "Michael Hunter"}
+)
+
+func TestParseByline(t *testing.T) {
+	tests := []struct {
+		byline   string
+		expected []BylineAuthor
+	}{
+		{"", nil},
+		{"Eric Platt", []BylineAuthor{{Name: "Eric Platt"}}},
+		{"By Eric Platt in New York", []BylineAuthor{{Name: "Eric Platt", Location: "New York"}}},
+		{
+			"Eric Platt in New York, Michael Hunter and Adam Samson in London",
+			[]BylineAuthor{{Name: "Eric Platt", Location: "New York"}, {Name: "Michael Hunter", Location: "London"}, {Name: "Adam Samson", Location: "London"}},
+		},
+		{
+			"Michael Hunter & Adam Samson; Eric Platt in New York",
+			[]BylineAuthor{{Name: "Michael Hunter"}, {Name: "Adam Samson"}, {Name: "Eric Platt", Location: "New York"}},
+		},
+		{
+			"Michael Hunter & Adam Samson in London; Eric Platt In New York",
+			[]BylineAuthor{{Name: "Michael Hunter", Location: "London"}, {Name: "Adam Samson", Location: "London"}, {Name: "Eric Platt", Location: "New York"}},
+		},
+		{
+			"Eric Platt in New York, and Michael Hunter",
+			[]BylineAuthor{{Name: "Eric Platt", Location: "New York"}, {Name: "Michael Hunter"}},
+		},
+		{"Eric Platt in Trinidad and Tobago", []BylineAuthor{{Name: "Eric Platt", Location: "Trinidad and Tobago"}}},
+		{
+			"Michael Hunter and Adam Samson in Bosnia and Herzegovina",
+			[]BylineAuthor{{Name: "Michael Hunter", Location: "Bosnia and Herzegovina"}, {Name: "Adam Samson", Location: "Bosnia and Herzegovina"}},
+		},
+		{
+			"Eric Platt in Trinidad and Tobago and Michael Hunter in London",
+			[]BylineAuthor{{Name: "Eric Platt", Location: "Trinidad and Tobago"}, {Name: "Michael Hunter", Location: "London"}},
+		},
+		{
+			"Eric Platt in New York and Michael Hunter in Bosnia and Herzegovina",
+			[]BylineAuthor{{Name: "Eric Platt", Location: "New York"}, {Name: "Michael Hunter", Location: "Bosnia and Herzegovina"}},
+		},
+		{"Additional reporting by Sandra Anderson", []BylineAuthor{{Name: "Sandra Anderson"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.byline, func(t *testing.T) {
+			assert.Equal(t, test.expected, ParseByline(test.byline))
+		})
+	}
+}
+
+func newTestBylineAuthorsSuggester(t *testing.T) *BylineAuthorsSuggester {
+	suggester, err := NewBylineAuthorsSuggester([]GazetteerEntry{
+		{Concept: ericPlatt},
+		{Concept: michaelHunter, Aliases: []string{"Mike Hunter"}},
+	}, logger.NewUPPLogger("test-service", "panic"))
+	require.NoError(t, err)
+	return suggester
+}
+
+func TestBylineAuthorsSuggester_GetSuggestions(t *testing.T) {
+	expect := assert.New(t)
+	suggester := newTestBylineAuthorsSuggester(t)
+
+	response, err := suggester.GetSuggestions([]byte(`{"byline":"Eric Platt in New York, mike hunter and Adam Samson in London","bodyXML":"text"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: Concept{ID: ericPlatt.ID, PrefLabel: "Eric Platt", Type: ontologyPersonType, IsFTAuthor: true}, Predicate: predicateHasAuthor},
+		{Concept: Concept{ID: michaelHunter.ID, PrefLabel: "Michael Hunter", Type: ontologyPersonType, IsFTAuthor: true}, Predicate: predicateHasAuthor},
+	}, response.Suggestions)
+	// the staff suggestions pass the filter of the authors suggester it replaces
+	expect.Equal(response.Suggestions, NewAuthorsSuggester("", "", nil).FilterSuggestions(response.Suggestions))
+}
+
+func TestBylineAuthorsSuggester_InvalidStaff(t *testing.T) {
+	_, err := NewBylineAuthorsSuggester([]GazetteerEntry{{Concept: Concept{PrefLabel: "Eric Platt"}}}, logger.NewUPPLogger("test-service", "panic"))
+	assert.EqualError(t, err, `staff member "Eric Platt" has no concept id`)
+}
+
+func TestBylineAuthorsSuggester_CrossChecked(t *testing.T) {
+	expect := assert.New(t)
+	bylineAuthors := newTestBylineAuthorsSuggester(t)
+	unsuggested := metrics.GetOrRegisterCounter("byline.crosscheck.unsuggested", metrics.DefaultRegistry).Count()
+	unexpected := metrics.GetOrRegisterCounter("byline.crosscheck.unexpected", metrics.DefaultRegistry).Count()
+
+	suggestions := []Suggestion{
+		// suggested under another label, matched on the UUID of the staff list
+		{Concept: Concept{ID: "http://api.ft.com/people/michael-hunter-uuid", PrefLabel: "Mike Hunter"}, Predicate: predicateHasAuthor},
+		{Concept: Concept{ID: "http://www.ft.com/thing/adam-samson-uuid", PrefLabel: "Adam Samson"}, Predicate: predicateHasAuthor},
+		{Concept: Concept{ID: "http://www.ft.com/thing/someone-uuid", PrefLabel: "Someone Else"}, Predicate: predicateHasAuthor},
+	}
+	authors := bylineAuthors.CrossChecked(&stubSuggester{name: "Authors", suggestions: suggestions})
+	response, err := authors.GetSuggestions([]byte(`{"byline":"Eric Platt in New York, Michael Hunter and Adam Samson in London"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Equal(suggestions, response.Suggestions)
+	expect.Equal("Authors", authors.GetName())
+	// Eric Platt wasn't suggested, Someone Else isn't in the byline
+	expect.Equal(unsuggested+1, metrics.GetOrRegisterCounter("byline.crosscheck.unsuggested", metrics.DefaultRegistry).Count())
+	expect.Equal(unexpected+1, metrics.GetOrRegisterCounter("byline.crosscheck.unexpected", metrics.DefaultRegistry).Count())
+}
+
+func TestBylineAuthorsSuggester_Fallback(t *testing.T) {
+	expect := assert.New(t)
+	bylineAuthors := newTestBylineAuthorsSuggester(t)
+	failing := &failingSuggester{stubSuggester: stubSuggester{name: "Authors"}, err: errors.New("connection refused")}
+
+	response, err := NewFallbackSuggester(bylineAuthors.CrossChecked(failing), bylineAuthors).GetSuggestions([]byte(`{"byline":"Eric Platt"}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Len(response.Suggestions, 1)
+	expect.Equal(ericPlatt.ID, response.Suggestions[0].ID)
+	expect.Equal([]string{DegradedSuggesterFallback}, response.Degraded)
+}
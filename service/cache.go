@@ -0,0 +1,199 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// lruCache is a size bounded cache whose entries also expire after a TTL.
+type lruCache struct {
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	// generation is bumped by each purge, so that values computed before it aren't cached after it.
+	generation uint64
+}
+
+type lruCacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newLRUCache(maxEntries int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruCacheEntry)
+	if c.now().After(entry.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *lruCache) set(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.store(key, value)
+}
+
+// setIfCurrent sets the value unless the cache was purged since the given generation.
+func (c *lruCache) setIfCurrent(key string, value interface{}, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation == c.generation {
+		c.store(key, value)
+	}
+}
+
+func (c *lruCache) currentGeneration() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+// purge removes all the entries.
+func (c *lruCache) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+	c.generation++
+}
+
+func (c *lruCache) store(key string, value interface{}) {
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruCacheEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruCacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruCacheEntry).key)
+}
+
+// SuggestionsCache keeps aggregated suggestions by the hash of the cleaned payload they were produced for.
+type SuggestionsCache struct {
+	cache *lruCache
+}
+
+func NewSuggestionsCache(maxEntries int, ttl time.Duration) *SuggestionsCache {
+	return &SuggestionsCache{cache: newLRUCache(maxEntries, ttl)}
+}
+
+func (c *SuggestionsCache) Get(key string) (SuggestionsResponse, bool) {
+	value, ok := c.cache.get(key)
+	if !ok {
+		return SuggestionsResponse{}, false
+	}
+	return copySuggestionsResponse(value.(SuggestionsResponse)), true
+}
+
+func (c *SuggestionsCache) Set(key string, resp SuggestionsResponse) {
+	c.cache.set(key, copySuggestionsResponse(resp))
+}
+
+// Purge drops all the cached suggestions, e.g. when the concepts they may contain are vetoed.
+// The suggestions aggregated before a purge and completed after it aren't cached.
+func (c *SuggestionsCache) Purge() {
+	c.cache.purge()
+}
+
+func (c *SuggestionsCache) generation() uint64 {
+	return c.cache.currentGeneration()
+}
+
+// setIfCurrent caches the suggestions unless the cache was purged since the given generation.
+func (c *SuggestionsCache) setIfCurrent(key string, resp SuggestionsResponse, generation uint64) {
+	c.cache.setIfCurrent(key, copySuggestionsResponse(resp), generation)
+}
+
+// ConceptsCache keeps the concorded concepts by the UUIDs they were looked up with.
+type ConceptsCache struct {
+	cache *lruCache
+}
+
+func NewConceptsCache(maxEntries int, ttl time.Duration) *ConceptsCache {
+	return &ConceptsCache{cache: newLRUCache(maxEntries, ttl)}
+}
+
+func (c *ConceptsCache) Get(id string) (Concept, bool) {
+	value, ok := c.cache.get(id)
+	if !ok {
+		return Concept{}, false
+	}
+	return value.(Concept), true
+}
+
+func (c *ConceptsCache) Set(id string, concept Concept) {
+	c.cache.set(id, concept)
+}
+
+// copySuggestionsResponse deep copies the response, so that neither the callers nor the cache see the changes the others make to theirs.
+func copySuggestionsResponse(resp SuggestionsResponse) SuggestionsResponse {
+	suggestions := make([]Suggestion, len(resp.Suggestions))
+	for i, suggestion := range resp.Suggestions {
+		suggestion.Sources = copyStrings(suggestion.Sources)
+		suggestion.BroaderOf = copyStrings(suggestion.BroaderOf)
+		suggestion.ImpliedBy = copyStrings(suggestion.ImpliedBy)
+		if suggestion.Details != nil {
+			details := *suggestion.Details
+			details.Aliases = copyStrings(details.Aliases)
+			suggestion.Details = &details
+		}
+		suggestions[i] = suggestion
+	}
+	resp.Suggestions = suggestions
+	resp.Degraded = copyStrings(resp.Degraded)
+	return resp
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
+
+func payloadHash(payload []byte) string {
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])
+}
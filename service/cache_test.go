@@ -0,0 +1,128 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	expect := assert.New(t)
+
+	cache := newLRUCache(2, time.Minute)
+	cache.set("a", 1)
+	cache.set("b", 2)
+	_, _ = cache.get("a")
+	cache.set("c", 3)
+
+	_, ok := cache.get("b")
+	expect.False(ok, "least recently used entry should be evicted")
+	value, ok := cache.get("a")
+	expect.True(ok)
+	expect.Equal(1, value)
+	value, ok = cache.get("c")
+	expect.True(ok)
+	expect.Equal(3, value)
+	expect.Equal(2, cache.len())
+}
+
+func TestLRUCache_ExpiresEntries(t *testing.T) {
+	expect := assert.New(t)
+
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	cache := newLRUCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", 1)
+	now = now.Add(59 * time.Second)
+	_, ok := cache.get("a")
+	expect.True(ok)
+
+	now = now.Add(2 * time.Second)
+	_, ok = cache.get("a")
+	expect.False(ok)
+	expect.Equal(0, cache.len())
+}
+
+func TestLRUCache_SetRefreshesExistingEntry(t *testing.T) {
+	expect := assert.New(t)
+
+	cache := newLRUCache(10, time.Minute)
+	cache.set("a", 1)
+	cache.set("a", 2)
+
+	value, ok := cache.get("a")
+	expect.True(ok)
+	expect.Equal(2, value)
+	expect.Equal(1, cache.len())
+}
+
+func TestSuggestionsCache_ReturnsCopies(t *testing.T) {
+	expect := assert.New(t)
+
+	cache := NewSuggestionsCache(10, time.Minute)
+	cache.Set("key", SuggestionsResponse{Suggestions: []Suggestion{{Concept: Concept{ID: "id-1"}}}})
+
+	cached, ok := cache.Get("key")
+	expect.True(ok)
+	cached.Suggestions[0].ID = "changed"
+
+	cached, ok = cache.Get("key")
+	expect.True(ok)
+	expect.Equal("id-1", cached.Suggestions[0].ID)
+}
+
+func TestSuggestionsCache_ReturnsDeepCopies(t *testing.T) {
+	expect := assert.New(t)
+
+	cache := NewSuggestionsCache(10, time.Minute)
+	cache.Set("key", SuggestionsResponse{
+		Suggestions: []Suggestion{{
+			Concept:   Concept{ID: "id-1"},
+			Sources:   []string{"ontotext"},
+			BroaderOf: []string{"id-2"},
+			ImpliedBy: []string{"id-3"},
+			Details:   &ConceptDetails{Aliases: []string{"alias"}, Ticker: "TCK"},
+		}},
+		Degraded: []string{"broader"},
+	})
+
+	cached, ok := cache.Get("key")
+	expect.True(ok)
+	cached.Suggestions[0].Sources[0] = "changed"
+	cached.Suggestions[0].BroaderOf[0] = "changed"
+	cached.Suggestions[0].ImpliedBy[0] = "changed"
+	cached.Suggestions[0].Details.Aliases[0] = "changed"
+	cached.Suggestions[0].Details.Ticker = "changed"
+	cached.Degraded[0] = "changed"
+
+	cached, ok = cache.Get("key")
+	expect.True(ok)
+	expect.Equal([]string{"ontotext"}, cached.Suggestions[0].Sources)
+	expect.Equal([]string{"id-2"}, cached.Suggestions[0].BroaderOf)
+	expect.Equal([]string{"id-3"}, cached.Suggestions[0].ImpliedBy)
+	expect.Equal(&ConceptDetails{Aliases: []string{"alias"}, Ticker: "TCK"}, cached.Suggestions[0].Details)
+	expect.Equal([]string{"broader"}, cached.Degraded)
+}
+
+func TestSuggestionsCache_Purge(t *testing.T) {
+	expect := assert.New(t)
+
+	cache := NewSuggestionsCache(10, time.Minute)
+	cache.Set("key", SuggestionsResponse{Suggestions: []Suggestion{{Concept: Concept{ID: "id-1"}}}})
+	generation := cache.generation()
+
+	cache.Purge()
+	_, ok := cache.Get("key")
+	expect.False(ok)
+
+	// suggestions aggregated before the purge aren't cached
+	cache.setIfCurrent("key", SuggestionsResponse{}, generation)
+	_, ok = cache.Get("key")
+	expect.False(ok)
+
+	cache.setIfCurrent("key", SuggestionsResponse{}, cache.generation())
+	_, ok = cache.Get("key")
+	expect.True(ok)
+}
@@ -5,19 +5,51 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/Financial-Times/go-fthealth/v1_1"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
 )
 
 const idsParamName = "ids"
 
+type ConcordanceFailureMode string
+
+const (
+	// ConcordanceFailureModeFail fails the request when internal concordances fails.
+	ConcordanceFailureModeFail ConcordanceFailureMode = "fail"
+	// ConcordanceFailureModeUnconcorded returns the suggestions as suggested, flagged as unconcorded.
+	ConcordanceFailureModeUnconcorded ConcordanceFailureMode = "unconcorded"
+	// ConcordanceFailureModeCache concords the suggestions with the concepts cached from previous lookups,
+	// returning the others unconcorded.
+	ConcordanceFailureModeCache ConcordanceFailureMode = "cache"
+
+	// DegradedUnconcorded reports that some suggestions couldn't be concorded.
+	DegradedUnconcorded = "unconcorded"
+	// DegradedConcordanceCache reports that the suggestions were concorded with cached concepts.
+	DegradedConcordanceCache = "concordance-cache"
+)
+
+func ParseConcordanceFailureMode(value string) (ConcordanceFailureMode, error) {
+	switch mode := ConcordanceFailureMode(value); mode {
+	case ConcordanceFailureModeFail, ConcordanceFailureModeUnconcorded, ConcordanceFailureModeCache:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown concordance failure mode %q", value)
+}
+
 type ConcordanceService struct {
 	systemId            string
 	name                string
 	ConcordanceBaseURL  string
 	ConcordanceEndpoint string
 	Client              Client
-	failureImpact       string
+	// IncludeDeprecated keeps the suggestions of deprecated concepts, flagged as such, instead of dropping them.
+	IncludeDeprecated bool
+	// Cache, when set, keeps the concepts of the successful lookups to fall back on.
+	Cache         *ConceptsCache
+	failureImpact string
+	inFlight      flightGroup
 }
 
 type ConcordanceResponse struct {
@@ -68,7 +100,28 @@ func (concordance *ConcordanceService) healthCheck() (string, error) {
 	return fmt.Sprintf("%v is healthy", concordance.name), nil
 }
 
+// Probe requests the concordances of a known concept and validates that it is resolved.
+func (concordance *ConcordanceService) Probe() (string, error) {
+	concorded, err := concordance.getConcordances([]string{HealthcheckConceptUUID}, tidutils.NewTransactionID())
+	if err != nil {
+		return "", err
+	}
+	c, ok := concorded.Concepts[HealthcheckConceptUUID]
+	if !ok || c.ID == "" {
+		return "", fmt.Errorf("%v did not return the concordance of concept %v", concordance.name, HealthcheckConceptUUID)
+	}
+	return fmt.Sprintf("%v resolved concept %v", concordance.name, HealthcheckConceptUUID), nil
+}
+
 func (concordance *ConcordanceService) getConcordances(ids []string, tid string) (ConcordanceResponse, error) {
+	result, err, _ := concordance.inFlight.do(idsKey(ids), func() (interface{}, error) {
+		return concordance.requestConcordances(ids, tid)
+	})
+	concorded, _ := result.(ConcordanceResponse)
+	return concorded, err
+}
+
+func (concordance *ConcordanceService) requestConcordances(ids []string, tid string) (ConcordanceResponse, error) {
 	var concorded ConcordanceResponse
 	req, err := http.NewRequest("GET", concordance.ConcordanceBaseURL+concordance.ConcordanceEndpoint, nil)
 	if err != nil {
@@ -81,7 +134,7 @@ func (concordance *ConcordanceService) getConcordances(ids []string, tid string)
 		queryParams.Add(idsParamName, id)
 	}
 
-	queryParams.Add("include_deprecated", "false")
+	queryParams.Add("include_deprecated", strconv.FormatBool(concordance.IncludeDeprecated))
 
 	req.URL.RawQuery = queryParams.Encode()
 
@@ -103,5 +156,10 @@ func (concordance *ConcordanceService) getConcordances(ids []string, tid string)
 	}
 
 	err = json.Unmarshal(body, &concorded)
+	if err == nil && concordance.Cache != nil {
+		for id, c := range concorded.Concepts {
+			concordance.Cache.Set(id, c)
+		}
+	}
 	return concorded, err
 }
@@ -2,9 +2,14 @@ package service
 
 import (
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -73,3 +78,89 @@ func TestConcordanceService_CheckHealthErrorOnRequestDo(t *testing.T) {
 	expect.Empty(checkResult)
 	mockClient.AssertExpectations(t)
 }
+
+func TestConcordanceService_ProbeSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Query().Get(idsParamName) == HealthcheckConceptUUID
+	})).Return(&http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"concepts":{"` + HealthcheckConceptUUID + `":{"id":"http://www.ft.com/thing/` + HealthcheckConceptUUID + `"}}}`)), StatusCode: http.StatusOK}, nil)
+
+	concordance := NewConcordance("http://test-url", "/internalconcordances", mockClient)
+	output, err := concordance.Probe()
+
+	expect.NoError(err)
+	expect.Equal("internal-concordances resolved concept "+HealthcheckConceptUUID, output)
+	mockClient.AssertExpectations(t)
+}
+
+func TestConcordanceService_ProbeConceptNotResolved(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"concepts":{}}`)), StatusCode: http.StatusOK}, nil)
+
+	concordance := NewConcordance("http://test-url", "/internalconcordances", mockClient)
+	output, err := concordance.Probe()
+
+	expect.Empty(output)
+	expect.EqualError(err, "internal-concordances did not return the concordance of concept "+HealthcheckConceptUUID)
+}
+
+func TestConcordanceService_GetConcordancesCollapsesConcurrentLookups(t *testing.T) {
+	expect := assert.New(t)
+
+	var calls int32
+	release := make(chan struct{})
+	concordance := NewConcordance("http://test-url", "/internalconcordances", stubHttpClient(func(*http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"concepts":{}}`)), StatusCode: http.StatusOK}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for _, ids := range [][]string{{"id-1", "id-2"}, {"id-2", "id-1"}, {"id-1", "id-2"}} {
+		wg.Add(1)
+		go func(ids []string) {
+			defer wg.Done()
+			_, err := concordance.getConcordances(ids, "tid_test")
+			expect.NoError(err)
+		}(ids)
+	}
+	// give all the lookups time to join the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	expect.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestConcordanceService_GetConcordancesIncludeDeprecated(t *testing.T) {
+	expect := assert.New(t)
+
+	var includeDeprecated []string
+	concordance := NewConcordance("internalConcordancesHost", "/internalconcordances", stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		includeDeprecated = append(includeDeprecated, req.URL.Query().Get("include_deprecated"))
+		return jsonResponder(`{"concepts":{}}`)(req)
+	}))
+
+	_, err := concordance.getConcordances([]string{"f758ef56-c40a-3162-91aa-3e8a3aabc495"}, "tid_test")
+	expect.NoError(err)
+	concordance.IncludeDeprecated = true
+	_, err = concordance.getConcordances([]string{"f758ef56-c40a-3162-91aa-3e8a3aabc495"}, "tid_test")
+	expect.NoError(err)
+
+	expect.Equal([]string{"false", "true"}, includeDeprecated)
+}
+
+func TestParseConcordanceFailureMode(t *testing.T) {
+	expect := assert.New(t)
+
+	for _, value := range []string{"fail", "unconcorded", "cache"} {
+		mode, err := ParseConcordanceFailureMode(value)
+		expect.NoError(err)
+		expect.Equal(ConcordanceFailureMode(value), mode)
+	}
+
+	_, err := ParseConcordanceFailureMode("ignore")
+	expect.EqualError(err, `unknown concordance failure mode "ignore"`)
+}
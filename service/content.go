@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Financial-Times/go-fthealth/v1_1"
+)
+
+var ContentNotFoundError = errors.New("Content API returned HTTP 404")
+
+type ContentRetriever interface {
+	GetContent(uuid string, tid string) ([]byte, error)
+	Check() v1_1.Check
+}
+
+type ContentAPI struct {
+	baseURL       string
+	endpoint      string
+	client        Client
+	systemID      string
+	name          string
+	failureImpact string
+}
+
+func NewContentRetriever(contentAPIBaseURL, contentEndpoint string, client Client) ContentRetriever {
+	return &ContentAPI{
+		baseURL:       contentAPIBaseURL,
+		endpoint:      contentEndpoint,
+		client:        client,
+		systemID:      "content-api",
+		name:          "content-api",
+		failureImpact: "Suggesting annotations for existing content by UUID will not work",
+	}
+}
+
+func (c *ContentAPI) GetContent(uuid string, tid string) ([]byte, error) {
+	preparedURL := fmt.Sprintf("%s/%s/%s", strings.TrimRight(c.baseURL, "/"), strings.Trim(c.endpoint, "/"), uuid)
+	req, err := http.NewRequest("GET", preparedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("User-Agent", "UPP public-suggestions-api")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Request-Id", tid)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ContentNotFoundError
+		}
+		return nil, fmt.Errorf("%v returned HTTP %v", c.name, resp.StatusCode)
+	}
+	return body, nil
+}
+
+func (c *ContentAPI) Check() v1_1.Check {
+	return v1_1.Check{
+		ID:               c.systemID,
+		BusinessImpact:   c.failureImpact,
+		Name:             fmt.Sprintf("%v Healthcheck", c.name),
+		PanicGuide:       PanicGuideURL + c.systemID,
+		Severity:         2,
+		TechnicalSummary: fmt.Sprintf("%v is not available", c.name),
+		Checker:          c.healthCheck,
+	}
+}
+
+func (c *ContentAPI) healthCheck() (string, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/__gtg", nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("User-Agent", "UPP public-suggestions-api")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Health check returned a non-200 HTTP status: %v", resp.StatusCode)
+	}
+	return fmt.Sprintf("%v is healthy", c.name), nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestContentAPI_GetContentSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "http://content-api/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09" &&
+			req.Header.Get("X-Request-Id") == "tid_test"
+	})).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`{"bodyXML":"<body>test</body>"}`)),
+		StatusCode: http.StatusOK,
+	}, nil)
+
+	retriever := NewContentRetriever("http://content-api/", "/content", mockClient)
+	content, err := retriever.GetContent("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.NoError(err)
+	expect.Equal(`{"bodyXML":"<body>test</body>"}`, string(content))
+	mockClient.AssertExpectations(t)
+}
+
+func TestContentAPI_GetContentNotFound(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`{"message":"not found"}`)),
+		StatusCode: http.StatusNotFound,
+	}, nil)
+
+	retriever := NewContentRetriever("http://content-api", "/content", mockClient)
+	content, err := retriever.GetContent("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.True(errors.Is(err, ContentNotFoundError))
+	expect.Nil(content)
+}
+
+func TestContentAPI_GetContentUnexpectedStatus(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		StatusCode: http.StatusInternalServerError,
+	}, nil)
+
+	retriever := NewContentRetriever("http://content-api", "/content", mockClient)
+	content, err := retriever.GetContent("9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test")
+
+	expect.EqualError(err, "content-api returned HTTP 500")
+	expect.Nil(content)
+}
+
+func TestContentAPI_CheckHealth(t *testing.T) {
+	expect := assert.New(t)
+	mockServer := new(mockSuggestionApiServer)
+	mockServer.On("GTG").Return(200).Once()
+	server := mockServer.startMockServer(t)
+	defer server.Close()
+
+	retriever := NewContentRetriever(server.URL, "/content", http.DefaultClient)
+	check := retriever.Check()
+	checkResult, err := check.Checker()
+
+	expect.Equal("content-api", check.ID)
+	expect.Equal("Suggesting annotations for existing content by UUID will not work", check.BusinessImpact)
+	expect.Equal("content-api Healthcheck", check.Name)
+	expect.Equal("https://runbooks.in.ft.com/content-api", check.PanicGuide)
+	expect.NoError(err)
+	expect.Equal("content-api is healthy", checkResult)
+	mock.AssertExpectationsForObjects(t, mockServer)
+}
+
+func TestContentAPI_CheckHealthUnhealthy(t *testing.T) {
+	expect := assert.New(t)
+	mockServer := new(mockSuggestionApiServer)
+	mockServer.On("GTG").Return(503)
+	server := mockServer.startMockServer(t)
+	defer server.Close()
+
+	retriever := NewContentRetriever(server.URL, "/content", http.DefaultClient)
+	checkResult, err := retriever.Check().Checker()
+
+	expect.Empty(checkResult)
+	expect.EqualError(err, "Health check returned a non-200 HTTP status: 503")
+}
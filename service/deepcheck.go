@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-fthealth/v1_1"
+)
+
+const healthcheckTimeFormat = time.RFC3339
+
+var (
+	// HealthcheckConceptUUID is the known concept used by the deep health checks to probe the concept related services.
+	HealthcheckConceptUUID = "f758ef56-c40a-3162-91aa-3e8a3aabc495"
+
+	healthcheckArticle = []byte(`{
+		"title": "Theresa May meets Angela Merkel in Berlin to discuss Brexit",
+		"byline": "George Parker in London and Guy Chazan in Berlin",
+		"bodyXML": "<body><p>Theresa May travelled to Berlin on Tuesday to meet Angela Merkel, as the UK prime minister sought support from European leaders for her Brexit deal. Apple and Google were also mentioned.</p></body>"
+	}`)
+
+	errProbeNotRun = errors.New("deep health check has not completed yet")
+	errNotProbed   = errors.New("the service can't be probed")
+)
+
+// prober is implemented by the services whose real endpoint can be probed by a deep health check.
+type prober interface {
+	Probe() (string, error)
+}
+
+// NewDeepHealthCheckOf builds the deep health check of the service, if it can be probed.
+func NewDeepHealthCheckOf(service interface{ Check() v1_1.Check }, interval time.Duration) (*DeepHealthCheck, bool) {
+	p, ok := service.(prober)
+	if !ok {
+		return nil, false
+	}
+	return NewDeepHealthCheck(service.Check(), p.Probe, interval), true
+}
+
+// DeepHealthCheck periodically sends a canned probe through the real endpoint of a downstream service
+// and caches the outcome, so the health endpoint reports it without waiting on the downstream service.
+type DeepHealthCheck struct {
+	check    v1_1.Check
+	probe    func() (string, error)
+	interval time.Duration
+	stop     chan struct{}
+
+	mutex       sync.RWMutex
+	output      string
+	err         error
+	lastChecked time.Time
+	lastSuccess time.Time
+}
+
+func NewDeepHealthCheck(check v1_1.Check, probe func() (string, error), interval time.Duration) *DeepHealthCheck {
+	return &DeepHealthCheck{
+		check:    check,
+		probe:    probe,
+		interval: interval,
+		stop:     make(chan struct{}),
+		err:      errProbeNotRun,
+	}
+}
+
+// Start runs the probe straight away and then on every interval, until Stop is called.
+func (d *DeepHealthCheck) Start() {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			d.run()
+			select {
+			case <-ticker.C:
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (d *DeepHealthCheck) Stop() {
+	close(d.stop)
+}
+
+func (d *DeepHealthCheck) run() {
+	output, err := d.probe()
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.output, d.err, d.lastChecked = output, err, now
+	if err == nil {
+		d.lastSuccess = now
+	}
+}
+
+// Check returns the health check of the downstream service with its checker replaced by the cached probe result.
+func (d *DeepHealthCheck) Check() v1_1.Check {
+	check := d.check
+	check.Checker = d.checker
+	return check
+}
+
+func (d *DeepHealthCheck) checker() (string, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	lastSuccess := "never"
+	if !d.lastSuccess.IsZero() {
+		lastSuccess = d.lastSuccess.Format(healthcheckTimeFormat)
+	}
+	if d.err != nil {
+		if d.lastChecked.IsZero() {
+			return "", d.err
+		}
+		return "", fmt.Errorf("%v (last checked at %v, last success %v)", d.err, d.lastChecked.Format(healthcheckTimeFormat), lastSuccess)
+	}
+	return fmt.Sprintf("%v (last checked at %v, last success %v)", d.output, d.lastChecked.Format(healthcheckTimeFormat), lastSuccess), nil
+}
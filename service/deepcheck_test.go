@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepHealthCheck_CheckerBeforeFirstProbe(t *testing.T) {
+	expect := assert.New(t)
+
+	deepCheck := NewDeepHealthCheck(v1_1.Check{ID: "test-check"}, func() (string, error) {
+		return "probe ok", nil
+	}, time.Minute)
+
+	check := deepCheck.Check()
+	output, err := check.Checker()
+
+	expect.Equal("test-check", check.ID)
+	expect.Empty(output)
+	expect.Equal(errProbeNotRun, err)
+}
+
+func TestDeepHealthCheck_CheckerReturnsCachedResult(t *testing.T) {
+	expect := assert.New(t)
+
+	calls := 0
+	deepCheck := NewDeepHealthCheck(v1_1.Check{ID: "test-check"}, func() (string, error) {
+		calls++
+		return "probe ok", nil
+	}, time.Minute)
+	deepCheck.run()
+
+	output, err := deepCheck.Check().Checker()
+	expect.NoError(err)
+	expect.Contains(output, "probe ok (last checked at ")
+	_, _ = deepCheck.Check().Checker()
+
+	expect.Equal(1, calls)
+}
+
+func TestDeepHealthCheck_CheckerKeepsLastSuccessOnFailure(t *testing.T) {
+	expect := assert.New(t)
+
+	var probeErr error
+	deepCheck := NewDeepHealthCheck(v1_1.Check{ID: "test-check"}, func() (string, error) {
+		return "probe ok", probeErr
+	}, time.Minute)
+	deepCheck.run()
+	lastSuccess := deepCheck.lastSuccess.Format(healthcheckTimeFormat)
+
+	probeErr = errors.New("unexpected response")
+	deepCheck.run()
+
+	output, err := deepCheck.Check().Checker()
+	expect.Empty(output)
+	expect.Error(err)
+	expect.Contains(err.Error(), "unexpected response")
+	expect.Contains(err.Error(), "last success "+lastSuccess)
+}
+
+func TestDeepHealthCheck_CheckerNeverSucceeded(t *testing.T) {
+	expect := assert.New(t)
+
+	deepCheck := NewDeepHealthCheck(v1_1.Check{ID: "test-check"}, func() (string, error) {
+		return "", errors.New("unexpected response")
+	}, time.Minute)
+	deepCheck.run()
+
+	_, err := deepCheck.Check().Checker()
+	expect.Error(err)
+	expect.Contains(err.Error(), "last success never")
+}
+
+func TestDeepHealthCheck_StartRunsProbePeriodically(t *testing.T) {
+	expect := assert.New(t)
+
+	probed := make(chan struct{}, 10)
+	deepCheck := NewDeepHealthCheck(v1_1.Check{ID: "test-check"}, func() (string, error) {
+		probed <- struct{}{}
+		return "probe ok", nil
+	}, 10*time.Millisecond)
+	deepCheck.Start()
+	defer deepCheck.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-probed:
+		case <-time.After(time.Second):
+			expect.FailNow("probe was not run")
+		}
+	}
+	_, err := deepCheck.Check().Checker()
+	expect.NoError(err)
+}
+
+func TestNewDeepHealthCheckOf(t *testing.T) {
+	expect := assert.New(t)
+
+	blacklister := NewConceptBlacklister("http://test-url", "/blacklist", jsonResponder(`{"uuids":["f758ef56-c40a-3162-91aa-3e8a3aabc495"]}`))
+	deepCheck, ok := NewDeepHealthCheckOf(blacklister, time.Minute)
+	expect.True(ok)
+	deepCheck.run()
+	output, err := deepCheck.Check().Checker()
+	expect.NoError(err)
+	expect.Contains(output, "returned 1 blacklisted concepts")
+
+	// implementations of the services without a probe keep their shallow checks
+	_, ok = NewDeepHealthCheckOf(shallowBlacklister{}, time.Minute)
+	expect.False(ok)
+	_, err = NewOverlayBlacklister(shallowBlacklister{}, nil).(prober).Probe()
+	expect.Equal(errNotProbed, err)
+}
+
+type shallowBlacklister struct{}
+
+func (shallowBlacklister) IsBlacklisted(uuid string, bl Blacklist) bool { return false }
+
+func (shallowBlacklister) GetBlacklist(tid string) (Blacklist, error) { return Blacklist{}, nil }
+
+func (shallowBlacklister) Check() v1_1.Check { return v1_1.Check{ID: "shallow"} }
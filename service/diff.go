@@ -0,0 +1,87 @@
+package service
+
+import (
+	fp "path/filepath"
+)
+
+type SuggestionsDiff struct {
+	Added          []Suggestion `json:"added"`
+	AlreadyPresent []Suggestion `json:"alreadyPresent"`
+	NotSuggested   []Suggestion `json:"notSuggested"`
+}
+
+// DiffSuggestions partitions the suggestions against the existing annotations of a content.
+// Both sides are matched on their concorded UUIDs, so an annotation made with a source identifier
+// still matches the canonical concept returned in the suggestions.
+func (s *AggregateSuggester) DiffSuggestions(suggestions []Suggestion, annotations []Annotation, tid string) (SuggestionsDiff, error) {
+	logEntry := s.Log.WithTransactionID(tid)
+
+	diff := SuggestionsDiff{
+		Added:          []Suggestion{},
+		AlreadyPresent: []Suggestion{},
+		NotSuggested:   []Suggestion{},
+	}
+
+	var ids []string
+	for _, annotation := range annotations {
+		ids = append(ids, fp.Base(annotation.ID))
+	}
+	ids = dedup(ids)
+
+	concorded := ConcordanceResponse{Concepts: map[string]Concept{}}
+	if len(ids) > 0 {
+		var err error
+		concorded, err = s.Concordance.getConcordances(ids, tid)
+		if err != nil {
+			return diff, err
+		}
+	}
+
+	existing := map[string]bool{}
+	var notSuggested []Suggestion
+	for _, annotation := range annotations {
+		annotated := Suggestion{
+			Predicate: annotation.Predicate,
+			Concept: Concept{
+				ID:        annotation.ID,
+				APIURL:    annotation.APIURL,
+				Type:      annotation.Type,
+				PrefLabel: annotation.PrefLabel,
+			},
+		}
+		if annotated.Type == "" && len(annotation.Types) > 0 {
+			annotated.Type = annotation.Types[len(annotation.Types)-1]
+		}
+		if c, ok := concorded.Concepts[fp.Base(annotation.ID)]; ok {
+			annotated.Concept = c
+		} else {
+			logEntry.Debugf("Existing annotation %v has no concordance, matching on its own UUID", annotation.ID)
+		}
+
+		id := fp.Base(annotated.ID)
+		if existing[id] {
+			continue
+		}
+		existing[id] = true
+		notSuggested = append(notSuggested, annotated)
+	}
+
+	suggested := map[string]bool{}
+	for _, suggestion := range suggestions {
+		id := fp.Base(suggestion.ID)
+		suggested[id] = true
+		if existing[id] {
+			diff.AlreadyPresent = append(diff.AlreadyPresent, suggestion)
+		} else {
+			diff.Added = append(diff.Added, suggestion)
+		}
+	}
+
+	for _, annotated := range notSuggested {
+		if !suggested[fp.Base(annotated.ID)] {
+			diff.NotSuggested = append(diff.NotSuggested, annotated)
+		}
+	}
+
+	return diff, nil
+}
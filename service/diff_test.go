@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateSuggester_DiffSuggestions(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{
+		ID:        "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495",
+		APIURL:    "http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc495",
+		PrefLabel: "London",
+		Type:      ontologyLocationType,
+	}
+	apple := Concept{
+		ID:        "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55",
+		APIURL:    "http://api.ft.com/organisations/9332270e-f959-3f55-9153-d30acd0d0a55",
+		PrefLabel: "Apple",
+		Type:      ontologyOrganisationType,
+	}
+	brexit := Concept{
+		ID:        "http://www.ft.com/thing/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990",
+		APIURL:    "http://api.ft.com/things/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990",
+		PrefLabel: "Brexit",
+		Type:      ontologyTopicType,
+	}
+
+	concordances, err := json.Marshal(ConcordanceResponse{Concepts: map[string]Concept{
+		// annotated with a source identifier which concords to London
+		"0a2a5a5e-7d5d-4d1b-9a51-6fc6de9aa001": london,
+		"7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990": brexit,
+	}})
+	require.NoError(t, err)
+
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       &ClosingBuffer{Buffer: bytes.NewBuffer(concordances)},
+		StatusCode: http.StatusOK,
+	}, nil)
+
+	suggester := NewAggregateSuggester(logger.NewUPPLogger("test-service", "panic"), NewConcordance("internalConcordancesHost", "/internalconcordances", mockClient), nil, nil)
+
+	diff, err := suggester.DiffSuggestions(
+		[]Suggestion{{Concept: london}, {Concept: apple}},
+		[]Annotation{
+			{ID: "http://api.ft.com/things/0a2a5a5e-7d5d-4d1b-9a51-6fc6de9aa001", Predicate: "http://www.ft.com/ontology/annotation/about"},
+			{ID: "http://api.ft.com/things/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990", Predicate: "http://www.ft.com/ontology/annotation/about"},
+		},
+		"tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: apple}}, diff.Added)
+	expect.Equal([]Suggestion{{Concept: london}}, diff.AlreadyPresent)
+	expect.Equal([]Suggestion{{Predicate: "http://www.ft.com/ontology/annotation/about", Concept: brexit}}, diff.NotSuggested)
+	mockClient.AssertNumberOfCalls(t, "Do", 1)
+}
+
+func TestAggregateSuggester_DiffSuggestionsNoAnnotations(t *testing.T) {
+	expect := assert.New(t)
+
+	mockClient := new(mockHttpClient)
+	suggester := NewAggregateSuggester(logger.NewUPPLogger("test-service", "panic"), NewConcordance("internalConcordancesHost", "/internalconcordances", mockClient), nil, nil)
+
+	suggestions := []Suggestion{{Concept: Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495"}}}
+	diff, err := suggester.DiffSuggestions(suggestions, nil, "tid_test")
+
+	expect.NoError(err)
+	expect.Equal(suggestions, diff.Added)
+	expect.Empty(diff.AlreadyPresent)
+	expect.Empty(diff.NotSuggested)
+	mockClient.AssertExpectations(t) // no calls
+}
+
+func TestAggregateSuggester_DiffSuggestionsConcordanceError(t *testing.T) {
+	expect := assert.New(t)
+
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{}, errors.New("timeout error"))
+	suggester := NewAggregateSuggester(logger.NewUPPLogger("test-service", "panic"), NewConcordance("internalConcordancesHost", "/internalconcordances", mockClient), nil, nil)
+
+	_, err := suggester.DiffSuggestions(nil, []Annotation{{ID: "http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc495"}}, "tid_test")
+
+	expect.EqualError(err, "timeout error")
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SuggesterFallback is a chain of suggesters for a concept type, e.g. author or personSource.
+// When the first suggester fails or has no content, the suggestions of the type made by the next suggester which didn't fail
+// are promoted, even though its FilterSuggestions would otherwise leave them out.
+type SuggesterFallback struct {
+	Type       string
+	Suggesters []string
+}
+
+// ParseSuggesterFallbacks parses chains of suggester names, primary first, as type=primary|secondary|...
+func ParseSuggesterFallbacks(values []string) ([]SuggesterFallback, error) {
+	var fallbacks []SuggesterFallback
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid suggester fallback %q, expected type=primary|secondary", value)
+		}
+		conceptType := strings.TrimSpace(parts[0])
+		if _, ok := typeValidators[conceptType]; !ok {
+			return nil, fmt.Errorf("invalid suggester fallback %q, unknown concept type %q", value, conceptType)
+		}
+		var suggesters []string
+		for _, name := range strings.Split(parts[1], "|") {
+			if name = strings.TrimSpace(name); name != "" {
+				suggesters = append(suggesters, name)
+			}
+		}
+		if len(suggesters) < 2 {
+			return nil, fmt.Errorf("invalid suggester fallback %q, expected a primary and at least one secondary suggester", value)
+		}
+		fallbacks = append(fallbacks, SuggesterFallback{Type: conceptType, Suggesters: suggesters})
+	}
+	return fallbacks, nil
+}
+
+// promoteFallbacks adds to the suggestions of every failed primary suggester of a fallback chain the suggestions of its type
+// made by the first secondary suggester which didn't fail, by their concorded type. It reports whether a failure, rather than a lack of content, was made up for.
+func (s *AggregateSuggester) promoteFallbacks(responseMap map[int][]Suggestion, errs map[int]error, tid string) bool {
+	if len(s.Fallbacks) == 0 {
+		return false
+	}
+	logEntry := s.Log.WithTransactionID(tid)
+
+	indexes := make(map[string]int, len(s.Suggesters))
+	for i, suggester := range s.Suggesters {
+		indexes[suggester.GetName()] = i
+	}
+
+	degraded := false
+	for _, fallback := range s.Fallbacks {
+		primary, ok := indexes[fallback.Suggesters[0]]
+		if !ok || errs[primary] == nil {
+			continue
+		}
+		for _, name := range fallback.Suggesters[1:] {
+			secondary, ok := indexes[name]
+			if !ok || errs[secondary] != nil {
+				continue
+			}
+
+			suggestions := append([]Suggestion{}, responseMap[primary]...)
+			promoted := 0
+			for _, suggestion := range responseMap[secondary] {
+				if typeValidators[fallback.Type](suggestion) {
+					if s.Merger != nil {
+						suggestion.Sources = []string{name}
+					}
+					suggestions = append(suggestions, suggestion)
+					promoted++
+				}
+			}
+			responseMap[primary] = suggestions
+
+			logEntry.Infof("Promoted %v %v suggestions of %v as %v failed", promoted, fallback.Type, name, fallback.Suggesters[0])
+			metrics.GetOrRegisterCounter("suggesters.fallback."+fallback.Type, metrics.DefaultRegistry).Inc(1)
+			if !errors.Is(errs[primary], NoContentError) && !errors.Is(errs[primary], BadRequestError) {
+				degraded = true
+			}
+			break
+		}
+	}
+	return degraded
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSuggesterFallbacks(t *testing.T) {
+	expect := assert.New(t)
+
+	fallbacks, err := ParseSuggesterFallbacks([]string{"author=Authors Suggestion API | Ontotext Suggestion API", "locationSource=Ontotext Suggestion API|Gazetteer|Suggestion Rules"})
+	expect.NoError(err)
+	expect.Equal([]SuggesterFallback{
+		{Type: PseudoConceptTypeAuthor, Suggesters: []string{"Authors Suggestion API", "Ontotext Suggestion API"}},
+		{Type: LocationSourceParam, Suggesters: []string{"Ontotext Suggestion API", "Gazetteer", "Suggestion Rules"}},
+	}, fallbacks)
+
+	for _, value := range []string{"author", "Person=Authors|Ontotext", "author=Authors Suggestion API", "author=Authors Suggestion API|"} {
+		_, err := ParseSuggesterFallbacks([]string{value})
+		expect.Errorf(err, value)
+	}
+}
+
+func TestAggregateSuggester_GetSuggestionsWithSuggesterFallbacks(t *testing.T) {
+	expect := assert.New(t)
+
+	ericPlatt := Concept{ID: "http://www.ft.com/thing/eric-platt-uuid", PrefLabel: "Eric Platt", Type: ontologyPersonType}
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	authors := NewAuthorsSuggester("authorsUrl", "/authors", stubHttpClient(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	ontotext := NewOntotextSuggester("ontotextUrl", "/ontotext", jsonResponder(`{"suggestions":[
+		{"id":"http://www.ft.com/thing/eric-platt-uuid","prefLabel":"Eric Platt","type":"http://www.ft.com/ontology/person/Person","predicate":"http://www.ft.com/ontology/annotation/hasAuthor"},
+		{"id":"http://www.ft.com/thing/london-uuid","prefLabel":"London","type":"http://www.ft.com/ontology/Location"}
+	]}`))
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"eric-platt-uuid": ericPlatt, "london-uuid": london}, authors, ontotext)
+	aggregateSuggester.Merger = NewSuggestionsMerger(nil)
+
+	// without fallback, Ontotext's authors are filtered out
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"byline":"Eric Platt","bodyXML":"London"}`), "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london, Sources: []string{"Ontotext Suggestion API"}}}, response.Suggestions)
+	expect.Empty(response.Degraded)
+
+	aggregateSuggester.Fallbacks = []SuggesterFallback{{Type: PseudoConceptTypeAuthor, Suggesters: []string{"Authors Suggestion API", "Ontotext Suggestion API"}}}
+	response, err = aggregateSuggester.GetSuggestions([]byte(`{"byline":"Eric Platt","bodyXML":"London"}`), "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{
+		{Concept: ericPlatt, Predicate: predicateHasAuthor, Sources: []string{"Ontotext Suggestion API"}},
+		{Concept: london, Sources: []string{"Ontotext Suggestion API"}},
+	}, response.Suggestions)
+	expect.Equal([]string{DegradedSuggesterFallback}, response.Degraded)
+}
+
+func TestAggregateSuggester_GetSuggestionsPromotesFallbacksByConcordedType(t *testing.T) {
+	expect := assert.New(t)
+
+	ericPlatt := Concept{ID: "http://www.ft.com/thing/eric-platt-uuid", PrefLabel: "Eric Platt", Type: ontologyPersonType}
+	authors := NewAuthorsSuggester("authorsUrl", "/authors", stubHttpClient(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	// Ontotext doesn't know Eric Platt is a person, which his concordance tells
+	ontotext := NewOntotextSuggester("ontotextUrl", "/ontotext", jsonResponder(`{"suggestions":[
+		{"id":"http://www.ft.com/thing/eric-platt-alias-uuid","prefLabel":"Eric Platt","type":"http://www.ft.com/ontology/core/Thing","predicate":"http://www.ft.com/ontology/annotation/hasAuthor"}
+	]}`))
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"eric-platt-alias-uuid": ericPlatt}, authors, ontotext)
+	aggregateSuggester.Fallbacks = []SuggesterFallback{{Type: PseudoConceptTypeAuthor, Suggesters: []string{"Authors Suggestion API", "Ontotext Suggestion API"}}}
+
+	response, err := aggregateSuggester.GetSuggestions([]byte(`{"byline":"Eric Platt"}`), "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: ericPlatt, Predicate: predicateHasAuthor, OriginalID: "http://www.ft.com/thing/eric-platt-alias-uuid"}}, response.Suggestions)
+	expect.Equal([]string{DegradedSuggesterFallback}, response.Degraded)
+}
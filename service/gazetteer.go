@@ -0,0 +1,241 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DegradedSuggesterFallback reports that the suggestions of a failing suggester were replaced, or made up for, by those of another suggester.
+const DegradedSuggesterFallback = "suggester-fallback"
+
+// GazetteerEntry is a concept recognised by its prefLabel or any of its aliases.
+type GazetteerEntry struct {
+	Concept
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// LoadGazetteer reads the JSON array of entries in the given file, e.g. a snapshot of the concepts of an ontology.
+func LoadGazetteer(path string) ([]GazetteerEntry, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []GazetteerEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GazetteerSuggester suggests the concepts whose prefLabel or aliases appear as whole words in the title or body of the content, regardless of case.
+// All the labels are matched in a single pass with an Aho-Corasick automaton, and overlapping matches are resolved in favour of the leftmost longest,
+// so "New York Times" isn't also suggested as "New York".
+type GazetteerSuggester struct {
+	name    string
+	entries []GazetteerEntry
+	matcher *labelMatcher
+}
+
+func NewGazetteerSuggester(entries []GazetteerEntry) (*GazetteerSuggester, error) {
+	matcher := newLabelMatcher()
+	for i, entry := range entries {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("gazetteer entry %q has no concept id", entry.PrefLabel)
+		}
+		for _, label := range append([]string{entry.PrefLabel}, entry.Aliases...) {
+			matcher.add(label, i)
+		}
+	}
+	matcher.build()
+	return &GazetteerSuggester{name: "Gazetteer", entries: entries, matcher: matcher}, nil
+}
+
+func (g *GazetteerSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	var input JsonInput
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return SuggestionsResponse{}, err
+	}
+
+	response := SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	seen := make(map[int]bool)
+	for _, text := range []string{input.Headline, input.Body} {
+		for _, i := range g.matcher.match(text) {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			response.Suggestions = append(response.Suggestions, Suggestion{
+				Concept:   g.entries[i].Concept,
+				Predicate: predicateMentions,
+			})
+		}
+	}
+	return response, nil
+}
+
+func (g *GazetteerSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return suggestions
+}
+
+func (g *GazetteerSuggester) GetName() string {
+	return g.name
+}
+
+// FallbackSuggester returns the suggestions of its fallback when its primary suggester fails.
+// The fallback suggestions are filtered like the primary ones, and reported as degraded.
+type FallbackSuggester struct {
+	Primary  Suggester
+	Fallback Suggester
+}
+
+func NewFallbackSuggester(primary Suggester, fallback Suggester) *FallbackSuggester {
+	return &FallbackSuggester{Primary: primary, Fallback: fallback}
+}
+
+func (f *FallbackSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	resp, err := f.Primary.GetSuggestions(payload, tid)
+	if err == nil || errors.Is(err, NoContentError) || errors.Is(err, BadRequestError) {
+		return resp, err
+	}
+
+	fallback, fErr := f.Fallback.GetSuggestions(payload, tid)
+	if fErr != nil {
+		return resp, err
+	}
+	fallback.Degraded = append(fallback.Degraded, DegradedSuggesterFallback)
+	return fallback, nil
+}
+
+func (f *FallbackSuggester) suggestsFromByline() bool {
+	return suggestsFromByline(f.Primary) && suggestsFromByline(f.Fallback)
+}
+
+func (f *FallbackSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return f.Primary.FilterSuggestions(suggestions)
+}
+
+func (f *FallbackSuggester) GetName() string {
+	return f.Primary.GetName()
+}
+
+// labelMatcher is an Aho-Corasick automaton over the runes of lower-cased labels.
+type labelMatcher struct {
+	nodes  []labelNode
+	labels []matcherLabel
+}
+
+type labelNode struct {
+	next map[rune]int
+	fail int
+	// outputs are the labels ending at this node, including through the failure links
+	outputs []int
+}
+
+type matcherLabel struct {
+	length int
+	value  int
+}
+
+type labelMatch struct {
+	start, end int
+	value      int
+}
+
+func newLabelMatcher() *labelMatcher {
+	return &labelMatcher{nodes: []labelNode{{next: map[rune]int{}}}}
+}
+
+func normaliseLabel(label string) []rune {
+	return []rune(strings.ToLower(strings.Join(strings.Fields(label), " ")))
+}
+
+func (m *labelMatcher) add(label string, value int) {
+	runes := normaliseLabel(label)
+	if len(runes) == 0 {
+		return
+	}
+	node := 0
+	for _, r := range runes {
+		next, ok := m.nodes[node].next[r]
+		if !ok {
+			next = len(m.nodes)
+			m.nodes = append(m.nodes, labelNode{next: map[rune]int{}})
+			m.nodes[node].next[r] = next
+		}
+		node = next
+	}
+	m.nodes[node].outputs = append(m.nodes[node].outputs, len(m.labels))
+	m.labels = append(m.labels, matcherLabel{length: len(runes), value: value})
+}
+
+// build sets the failure links breadth first, so the links of shallower nodes are set before they are followed.
+func (m *labelMatcher) build() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[node].next {
+			fail := m.nodes[node].fail
+			for fail != 0 && m.nodes[fail].next[r] == 0 {
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[m.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// match returns the values of the labels found as whole words in the text, in order of appearance.
+func (m *labelMatcher) match(text string) []int {
+	runes := []rune(strings.ToLower(text))
+	var matches []labelMatch
+	node := 0
+	for i, r := range runes {
+		for node != 0 && m.nodes[node].next[r] == 0 {
+			node = m.nodes[node].fail
+		}
+		node = m.nodes[node].next[r]
+		for _, l := range m.nodes[node].outputs {
+			label := m.labels[l]
+			start := i - label.length + 1
+			if isWordBoundary(runes, start-1) && isWordBoundary(runes, i+1) {
+				matches = append(matches, labelMatch{start: start, end: i + 1, value: label.value})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+	var values []int
+	end := 0
+	for _, match := range matches {
+		if match.start < end {
+			continue
+		}
+		values = append(values, match.value)
+		end = match.end
+	}
+	return values
+}
+
+func isWordBoundary(runes []rune, i int) bool {
+	if i < 0 || i >= len(runes) {
+		return true
+	}
+	return !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i])
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	newYork      = Concept{ID: "http://www.ft.com/thing/new-york-uuid", PrefLabel: "New York", Type: ontologyLocationType}
+	newYorkTimes = Concept{ID: "http://www.ft.com/thing/nyt-uuid", PrefLabel: "New York Times", Type: ontologyOrganisationType}
+	york         = Concept{ID: "http://www.ft.com/thing/york-uuid", PrefLabel: "York", Type: ontologyLocationType}
+	saoPaulo     = Concept{ID: "http://www.ft.com/thing/sao-paulo-uuid", PrefLabel: "São Paulo", Type: ontologyLocationType}
+	unitedStates = Concept{ID: "http://www.ft.com/thing/us-uuid", PrefLabel: "United States", Type: ontologyLocationType}
+)
+
+func newTestGazetteerSuggester(t *testing.T) *GazetteerSuggester {
+	suggester, err := NewGazetteerSuggester([]GazetteerEntry{
+		{Concept: newYork, Aliases: []string{"NYC"}},
+		{Concept: newYorkTimes, Aliases: []string{"NYT"}},
+		{Concept: york},
+		{Concept: saoPaulo},
+		{Concept: unitedStates, Aliases: []string{"US", "USA", "United  States of America"}},
+	})
+	require.NoError(t, err)
+	return suggester
+}
+
+func TestGazetteerSuggester_GetSuggestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected []Concept
+	}{
+		{"labels in order of appearance", `{"title":"York","bodyXML":"From New York to São Paulo"}`, []Concept{york, newYork, saoPaulo}},
+		{"aliases regardless of case", `{"bodyXML":"nyc and the usa"}`, []Concept{newYork, unitedStates}},
+		{"longest overlapping label", `{"bodyXML":"The New York Times reported"}`, []Concept{newYorkTimes}},
+		{"overlapping labels further on", `{"bodyXML":"New York and the New York Times"}`, []Concept{newYork, newYorkTimes}},
+		{"whole words only", `{"bodyXML":"Yorkshire, NYCE, census and Newyork"}`, []Concept{}},
+		{"each concept once", `{"title":"US","bodyXML":"the United States of America, the US"}`, []Concept{unitedStates}},
+		{"punctuation", `{"bodyXML":"(York), São Paulo's mayor"}`, []Concept{york, saoPaulo}},
+	}
+
+	suggester := newTestGazetteerSuggester(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := suggester.GetSuggestions([]byte(test.payload), "tid_test")
+			require.NoError(t, err)
+
+			concepts := []Concept{}
+			for _, suggestion := range response.Suggestions {
+				assert.Equal(t, predicateMentions, suggestion.Predicate)
+				concepts = append(concepts, suggestion.Concept)
+			}
+			assert.Equal(t, test.expected, concepts)
+		})
+	}
+}
+
+func TestGazetteerSuggester_InvalidEntries(t *testing.T) {
+	_, err := NewGazetteerSuggester([]GazetteerEntry{{Concept: Concept{PrefLabel: "London"}}})
+	assert.EqualError(t, err, `gazetteer entry "London" has no concept id`)
+}
+
+func TestLoadGazetteer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gazetteer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gazetteer.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"id":"http://www.ft.com/thing/new-york-uuid","prefLabel":"New York","type":"http://www.ft.com/ontology/Location","aliases":["NYC"]}]`), 0644))
+
+	entries, err := LoadGazetteer(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []GazetteerEntry{{Concept: newYork, Aliases: []string{"NYC"}}}, entries)
+}
+
+type failingSuggester struct {
+	stubSuggester
+	err error
+}
+
+func (s *failingSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	return SuggestionsResponse{}, s.err
+}
+
+func TestFallbackSuggester(t *testing.T) {
+	expect := assert.New(t)
+	payload := []byte(`{"bodyXML":"New York"}`)
+	gazetteer := newTestGazetteerSuggester(t)
+
+	primary := &stubSuggester{name: "Ontotext Suggestion API", suggestions: []Suggestion{{Concept: york}}}
+	resp, err := NewFallbackSuggester(primary, gazetteer).GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: york}}, resp.Suggestions)
+	expect.Empty(resp.Degraded)
+
+	failing := &failingSuggester{stubSuggester: stubSuggester{name: "Ontotext Suggestion API"}, err: errors.New("connection refused")}
+	fallback := NewFallbackSuggester(failing, gazetteer)
+	resp, err = fallback.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: newYork, Predicate: predicateMentions}}, resp.Suggestions)
+	expect.Equal([]string{DegradedSuggesterFallback}, resp.Degraded)
+	expect.Equal("Ontotext Suggestion API", fallback.GetName())
+
+	noContent := &failingSuggester{err: NoContentError}
+	_, err = NewFallbackSuggester(noContent, gazetteer).GetSuggestions(payload, "tid_test")
+	expect.Equal(NoContentError, err)
+}
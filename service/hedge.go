@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// minHedgingSamples is the number of latencies recorded before the hedging delay follows their percentile rather than the minimum delay.
+const minHedgingSamples = 20
+
+// Hedging sends a second identical request when the first one is slower than most recent requests, and keeps whichever answers first.
+type Hedging struct {
+	// Percentile of the recent latencies after which the request is hedged, e.g. 0.95.
+	Percentile float64
+	// MinDelay bounds the delay from below, and is the delay until enough latencies are recorded.
+	MinDelay time.Duration
+	// BaseURL is where the hedged request is sent. It defaults to the base URL of the first request.
+	BaseURL string
+
+	latencies metrics.Histogram
+	fired     metrics.Counter
+	won       metrics.Counter
+}
+
+type hedgedResult struct {
+	resp   SuggestionsResponse
+	err    error
+	hedged bool
+}
+
+// NewHedging builds a hedging policy recording its latencies and outcomes in metrics named after the system.
+func NewHedging(systemId string, percentile float64, minDelay time.Duration, baseURL string) *Hedging {
+	return &Hedging{
+		Percentile: percentile,
+		MinDelay:   minDelay,
+		BaseURL:    baseURL,
+		latencies:  metrics.GetOrRegisterHistogram("hedging."+systemId+".latency", metrics.DefaultRegistry, metrics.NewExpDecaySample(1028, 0.015)),
+		fired:      metrics.GetOrRegisterCounter("hedging."+systemId+".fired", metrics.DefaultRegistry),
+		won:        metrics.GetOrRegisterCounter("hedging."+systemId+".won", metrics.DefaultRegistry),
+	}
+}
+
+func (h *Hedging) delay() time.Duration {
+	if h.latencies.Count() < minHedgingSamples {
+		return h.MinDelay
+	}
+	delay := time.Duration(h.latencies.Percentile(h.Percentile))
+	if delay < h.MinDelay {
+		return h.MinDelay
+	}
+	return delay
+}
+
+// hedgedSuggestions requests the suggestions from the primary base URL and, past the hedging delay, from the hedging base URL too.
+// The first answer wins, unless it is an error and the other request is still pending. The losing request is cancelled.
+// The latency recorded is the time to the winning answer from the start of the first request, which bounds the latency of a cancelled
+// first request from below, so that the slow requests keep being sampled and the hedging delay doesn't drift down to the fast ones.
+func (suggester *SuggestionApi) hedgedSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	h := suggester.Hedging
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	results := make(chan hedgedResult, 2)
+	request := func(baseURL string, hedged bool) {
+		resp, err := suggester.requestSuggestions(ctx, baseURL, payload, tid)
+		results <- hedgedResult{resp: resp, err: err, hedged: hedged}
+	}
+	record := func(result hedgedResult) (SuggestionsResponse, error) {
+		if result.err == nil {
+			h.latencies.Update(int64(time.Since(start)))
+		}
+		return result.resp, result.err
+	}
+	go request(suggester.apiBaseURL, false)
+
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		return record(result)
+	case <-timer.C:
+	}
+
+	baseURL := h.BaseURL
+	if baseURL == "" {
+		baseURL = suggester.apiBaseURL
+	}
+	h.fired.Inc(1)
+	go request(baseURL, true)
+
+	result := <-results
+	if result.err != nil && !errors.Is(result.err, NoContentError) && !errors.Is(result.err, BadRequestError) {
+		result = <-results
+	}
+	if result.hedged {
+		h.won.Inc(1)
+	}
+	return record(result)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+const hedgingTestResponse = `{"suggestions":[{"id":"http://www.ft.com/thing/london-uuid","prefLabel":"London","type":"http://www.ft.com/ontology/Location"}]}`
+
+// hostResponder answers the requests to each host after its delay, or fails them when it has no response.
+// The requests are cancellable while they wait.
+func hostResponder(delays map[string]time.Duration, cancelled chan<- string) stubHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		delay, ok := delays[req.URL.Host]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		select {
+		case <-time.After(delay):
+			return &http.Response{Body: ioutil.NopCloser(strings.NewReader(hedgingTestResponse)), StatusCode: http.StatusOK}, nil
+		case <-req.Context().Done():
+			cancelled <- req.URL.Host
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// newTestHedging builds a hedging policy with fresh metrics.
+func newTestHedging(systemId string, minDelay time.Duration, alternate string) *Hedging {
+	for _, metric := range []string{"latency", "fired", "won"} {
+		metrics.DefaultRegistry.Unregister("hedging." + systemId + "." + metric)
+	}
+	return NewHedging(systemId, 0.9, minDelay, alternate)
+}
+
+func newHedgedTestSuggester(systemId string, client Client, alternate string) *OntotextSuggester {
+	suggester := NewOntotextSuggester("http://primary", "/ontotext", client)
+	suggester.Hedging = newTestHedging(systemId, 20*time.Millisecond, alternate)
+	return suggester
+}
+
+func TestSuggestionApi_HedgingNotNeeded(t *testing.T) {
+	expect := assert.New(t)
+	suggester := newHedgedTestSuggester("hedging-not-needed", hostResponder(map[string]time.Duration{"primary": 0}, nil), "http://alternate")
+
+	resp, err := suggester.GetSuggestions([]byte(`{}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Len(resp.Suggestions, 1)
+	expect.Equal(int64(0), suggester.Hedging.fired.Count())
+	expect.Equal(int64(1), suggester.Hedging.latencies.Count())
+}
+
+func TestSuggestionApi_HedgingWins(t *testing.T) {
+	expect := assert.New(t)
+	cancelled := make(chan string, 2)
+	client := hostResponder(map[string]time.Duration{"primary": time.Second, "alternate": 0}, cancelled)
+	suggester := newHedgedTestSuggester("hedging-wins", client, "http://alternate")
+
+	start := time.Now()
+	resp, err := suggester.GetSuggestions([]byte(`{}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Len(resp.Suggestions, 1)
+	expect.True(time.Since(start) < time.Second)
+	expect.Equal(int64(1), suggester.Hedging.fired.Count())
+	expect.Equal(int64(1), suggester.Hedging.won.Count())
+	select {
+	case host := <-cancelled:
+		expect.Equal("primary", host)
+	case <-time.After(time.Second):
+		expect.Fail("the slow request wasn't cancelled")
+	}
+}
+
+func TestSuggestionApi_HedgingLoses(t *testing.T) {
+	expect := assert.New(t)
+	client := hostResponder(map[string]time.Duration{"primary": 80 * time.Millisecond}, nil)
+	// the hedged request to the same base URL takes as long, so the first request answers first
+	suggester := newHedgedTestSuggester("hedging-loses", client, "")
+	suggester.Hedging.MinDelay = 50 * time.Millisecond
+
+	resp, err := suggester.GetSuggestions([]byte(`{}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Len(resp.Suggestions, 1)
+	expect.Equal(int64(1), suggester.Hedging.fired.Count())
+	expect.Equal(int64(0), suggester.Hedging.won.Count())
+}
+
+func TestSuggestionApi_HedgingWaitsForTheOtherRequestOnError(t *testing.T) {
+	expect := assert.New(t)
+	// the hedged request fails straight away, while the first one is still pending
+	client := hostResponder(map[string]time.Duration{"primary": 50 * time.Millisecond}, nil)
+	suggester := newHedgedTestSuggester("hedging-error", client, "http://unavailable")
+
+	resp, err := suggester.GetSuggestions([]byte(`{}`), "tid_test")
+
+	expect.NoError(err)
+	expect.Len(resp.Suggestions, 1)
+	expect.Equal(int64(0), suggester.Hedging.won.Count())
+}
+
+func TestSuggestionApi_HedgingDelayStableUnderSlowTail(t *testing.T) {
+	expect := assert.New(t)
+	// the first requests are all slow, and the hedged ones fast
+	client := hostResponder(map[string]time.Duration{"primary": time.Second, "alternate": 0}, make(chan string, 100))
+	suggester := newHedgedTestSuggester("hedging-slow-tail", client, "http://alternate")
+	suggester.Hedging.Percentile = 0.5
+	suggester.Hedging.MinDelay = time.Millisecond
+	for i := 0; i < minHedgingSamples; i++ {
+		suggester.Hedging.latencies.Update(int64(20 * time.Millisecond))
+	}
+
+	for i := 0; i <= minHedgingSamples; i++ {
+		_, err := suggester.GetSuggestions([]byte(`{}`), "tid_test")
+		expect.NoError(err)
+	}
+
+	// the answers took at least the hedging delay, which sampling the fast hedged requests alone would have dragged down
+	expect.Equal(int64(minHedgingSamples+1), suggester.Hedging.won.Count())
+	expect.True(suggester.Hedging.delay() >= 20*time.Millisecond, suggester.Hedging.delay().String())
+}
+
+func TestHedging_Delay(t *testing.T) {
+	expect := assert.New(t)
+	hedging := newTestHedging("hedging-delay", 10*time.Millisecond, "")
+
+	expect.Equal(10*time.Millisecond, hedging.delay())
+	for i := 1; i <= minHedgingSamples*5; i++ {
+		hedging.latencies.Update(int64(time.Duration(i) * time.Millisecond))
+	}
+	expect.InDelta(float64(90*time.Millisecond), float64(hedging.delay()), float64(time.Millisecond))
+
+	hedging.MinDelay = time.Second
+	expect.Equal(time.Second, hedging.delay())
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Lane is the priority of a suggestion request.
+type Lane string
+
+const (
+	// LaneInteractive is for the requests of editors waiting for the suggestions. It is the lane of the requests without one.
+	LaneInteractive Lane = "interactive"
+	// LaneBulk is for the requests of batch jobs, which only use the capacity the interactive requests leave spare.
+	LaneBulk Lane = "bulk"
+)
+
+var ErrLaneSaturated = errors.New("no capacity left for the request in its lane")
+
+// laneSlotKey is the key of the context value telling the lane a request already holds a slot in.
+type laneSlotKey struct{}
+
+func ParseLane(value string) (Lane, error) {
+	switch l := Lane(value); l {
+	case LaneInteractive, LaneBulk:
+		return l, nil
+	}
+	return "", fmt.Errorf("invalid lane %q, expected %v or %v", value, LaneInteractive, LaneBulk)
+}
+
+// priority returns the lane the requests of the lane run in, the requests without one being interactive.
+func (l Lane) priority() Lane {
+	if l == LaneBulk {
+		return LaneBulk
+	}
+	return LaneInteractive
+}
+
+// Lanes share the aggregations run at once between the interactive and bulk requests. The interactive requests can use all the capacity,
+// while the bulk requests use at most their own capacity, and only when no interactive request is waiting. Each lane has its own queue,
+// and when a slot frees up, the interactive queue is served first.
+type Lanes struct {
+	// InteractiveMaxWait and BulkMaxWait are how long the requests wait for a slot in each lane before failing with ErrLaneSaturated. 0 waits until there is one.
+	InteractiveMaxWait time.Duration
+	BulkMaxWait        time.Duration
+
+	capacity     int
+	bulkCapacity int
+	metrics      map[Lane]*laneMetrics
+
+	mutex    sync.Mutex
+	inFlight map[Lane]int
+	queues   map[Lane][]chan struct{}
+}
+
+type laneMetrics struct {
+	queued   metrics.Timer
+	latency  metrics.Timer
+	inFlight metrics.Gauge
+	rejected metrics.Counter
+}
+
+// NewLanes builds lanes of the given capacity, up to bulkCapacity of which the bulk requests can use.
+func NewLanes(capacity int, bulkCapacity int) *Lanes {
+	if bulkCapacity > capacity {
+		bulkCapacity = capacity
+	}
+	l := &Lanes{
+		capacity:     capacity,
+		bulkCapacity: bulkCapacity,
+		metrics:      map[Lane]*laneMetrics{},
+		inFlight:     map[Lane]int{},
+		queues:       map[Lane][]chan struct{}{},
+	}
+	for _, lane := range []Lane{LaneInteractive, LaneBulk} {
+		l.metrics[lane] = &laneMetrics{
+			queued:   metrics.GetOrRegisterTimer("lanes."+string(lane)+".queued", metrics.DefaultRegistry),
+			latency:  metrics.GetOrRegisterTimer("lanes."+string(lane)+".latency", metrics.DefaultRegistry),
+			inFlight: metrics.GetOrRegisterGauge("lanes."+string(lane)+".inflight", metrics.DefaultRegistry),
+			rejected: metrics.GetOrRegisterCounter("lanes."+string(lane)+".rejected", metrics.DefaultRegistry),
+		}
+	}
+	return l
+}
+
+// Enter waits for a slot in the lane, for requests doing more than aggregating in their turn, e.g. fetching the content to aggregate.
+// It fails with ErrLaneSaturated when no slot frees up in time, or with the error of the context when it is done first.
+// The aggregations run with the returned context use the slot held, which is released by calling leave.
+func (l *Lanes) Enter(ctx context.Context, lane Lane) (held context.Context, leave func(), err error) {
+	lane = lane.priority()
+	m := l.metrics[lane]
+
+	start := time.Now()
+	if err := l.acquire(ctx, lane); err != nil {
+		if errors.Is(err, ErrLaneSaturated) {
+			m.rejected.Inc(1)
+		}
+		return ctx, nil, err
+	}
+	m.queued.UpdateSince(start)
+
+	started := time.Now()
+	leave = func() {
+		m.latency.UpdateSince(started)
+		l.release(lane)
+	}
+	return context.WithValue(ctx, laneSlotKey{}, lane), leave, nil
+}
+
+// run runs the aggregation in its turn in the lane, or right away when the context already holds a slot.
+func (l *Lanes) run(ctx context.Context, lane Lane, aggregate func() (interface{}, error)) (interface{}, error) {
+	if _, ok := ctx.Value(laneSlotKey{}).(Lane); ok {
+		return aggregate()
+	}
+	_, leave, err := l.Enter(ctx, lane)
+	if err != nil {
+		return SuggestionsResponse{Suggestions: make([]Suggestion, 0)}, err
+	}
+	defer leave()
+	return aggregate()
+}
+
+// available tells whether a request of the lane can start now, ignoring the requests queued in the lane itself.
+func (l *Lanes) available(lane Lane) bool {
+	total := l.inFlight[LaneInteractive] + l.inFlight[LaneBulk]
+	if total >= l.capacity {
+		return false
+	}
+	return lane == LaneInteractive || (l.inFlight[LaneBulk] < l.bulkCapacity && len(l.queues[LaneInteractive]) == 0)
+}
+
+func (l *Lanes) start(lane Lane) {
+	l.inFlight[lane]++
+	l.metrics[lane].inFlight.Update(int64(l.inFlight[lane]))
+}
+
+// acquire waits for a slot in the lane, failing with ErrLaneSaturated when none frees up in time, or with the error of the context when it is done first.
+func (l *Lanes) acquire(ctx context.Context, lane Lane) error {
+	l.mutex.Lock()
+	if len(l.queues[lane]) == 0 && l.available(lane) {
+		l.start(lane)
+		l.mutex.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	l.queues[lane] = append(l.queues[lane], turn)
+	l.mutex.Unlock()
+
+	maxWait := l.InteractiveMaxWait
+	if lane == LaneBulk {
+		maxWait = l.BulkMaxWait
+	}
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	err := ErrLaneSaturated
+	select {
+	case <-turn:
+		return nil
+	case <-timeout:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	queue := l.queues[lane]
+	for i, waiting := range queue {
+		if waiting == turn {
+			l.queues[lane] = append(queue[:i], queue[i+1:]...)
+			// a bulk request may have been held back by this one
+			l.dispatch()
+			return err
+		}
+	}
+	// its turn came while giving up
+	return nil
+}
+
+func (l *Lanes) release(lane Lane) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight[lane]--
+	l.metrics[lane].inFlight.Update(int64(l.inFlight[lane]))
+	l.dispatch()
+}
+
+// dispatch starts the queued requests there is capacity for, the interactive ones first.
+func (l *Lanes) dispatch() {
+	for _, lane := range []Lane{LaneInteractive, LaneBulk} {
+		for len(l.queues[lane]) > 0 && l.available(lane) {
+			turn := l.queues[lane][0]
+			l.queues[lane] = l.queues[lane][1:]
+			l.start(lane)
+			close(turn)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// laneRun is an aggregation run in a lane, completing when released.
+type laneRun struct {
+	started chan struct{}
+	release chan struct{}
+	done    chan error
+}
+
+func startLaneRun(lanes *Lanes, lane Lane) *laneRun {
+	run := &laneRun{started: make(chan struct{}), release: make(chan struct{}), done: make(chan error, 1)}
+	go func() {
+		_, err := lanes.run(context.Background(), lane, func() (interface{}, error) {
+			close(run.started)
+			<-run.release
+			return SuggestionsResponse{}, nil
+		})
+		run.done <- err
+	}()
+	return run
+}
+
+func (r *laneRun) isStarted() bool {
+	select {
+	case <-r.started:
+		return true
+	case <-time.After(20 * time.Millisecond):
+		return false
+	}
+}
+
+func (r *laneRun) finish(t *testing.T) {
+	close(r.release)
+	require.NoError(t, <-r.done)
+}
+
+func TestLanes_BulkOnlyUsesSpareCapacity(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(3, 2)
+
+	bulk1 := startLaneRun(lanes, LaneBulk)
+	expect.True(bulk1.isStarted())
+	bulk2 := startLaneRun(lanes, LaneBulk)
+	expect.True(bulk2.isStarted())
+	// over the bulk capacity
+	bulk3 := startLaneRun(lanes, LaneBulk)
+	expect.False(bulk3.isStarted())
+
+	// the capacity left is the interactive lane's
+	interactive1 := startLaneRun(lanes, LaneInteractive)
+	expect.True(interactive1.isStarted())
+	interactive2 := startLaneRun(lanes, LaneInteractive)
+	expect.False(interactive2.isStarted())
+
+	// the interactive request waiting goes first
+	bulk1.finish(t)
+	expect.True(interactive2.isStarted())
+	expect.False(bulk3.isStarted())
+
+	interactive1.finish(t)
+	expect.True(bulk3.isStarted())
+
+	for _, run := range []*laneRun{bulk2, bulk3, interactive2} {
+		run.finish(t)
+	}
+	expect.Zero(lanes.inFlight[LaneInteractive] + lanes.inFlight[LaneBulk])
+}
+
+func TestLanes_InteractiveUsesAllCapacity(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(2, 1)
+
+	runs := make([]*laneRun, 0, 2)
+	for _, lane := range []Lane{LaneInteractive, ""} {
+		run := startLaneRun(lanes, lane)
+		expect.True(run.isStarted())
+		runs = append(runs, run)
+	}
+	bulk := startLaneRun(lanes, LaneBulk)
+	expect.False(bulk.isStarted())
+
+	runs[0].finish(t)
+	expect.True(bulk.isStarted())
+	runs[1].finish(t)
+	bulk.finish(t)
+}
+
+func TestLanes_MaxWait(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(1, 1)
+	lanes.InteractiveMaxWait = time.Minute
+	lanes.BulkMaxWait = 10 * time.Millisecond
+	rejected := lanes.metrics[LaneBulk].rejected.Count()
+
+	interactive := startLaneRun(lanes, LaneInteractive)
+	expect.True(interactive.isStarted())
+
+	_, err := lanes.run(context.Background(), LaneBulk, func() (interface{}, error) {
+		t.Fatal("bulk request ran over the capacity")
+		return nil, nil
+	})
+	expect.Equal(ErrLaneSaturated, err)
+	expect.Equal(rejected+1, lanes.metrics[LaneBulk].rejected.Count())
+	expect.Empty(lanes.queues[LaneBulk])
+
+	interactive.finish(t)
+}
+
+func TestLanes_CancelledWhileWaiting(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(1, 1)
+	rejected := lanes.metrics[LaneInteractive].rejected.Count()
+
+	interactive := startLaneRun(lanes, LaneInteractive)
+	expect.True(interactive.isStarted())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := lanes.run(ctx, LaneInteractive, func() (interface{}, error) {
+		t.Fatal("cancelled request ran over the capacity")
+		return nil, nil
+	})
+	expect.Equal(context.Canceled, err)
+	expect.Equal(rejected, lanes.metrics[LaneInteractive].rejected.Count())
+	expect.Empty(lanes.queues[LaneInteractive])
+
+	interactive.finish(t)
+}
+
+func TestLanes_EnterHoldsTheSlotOfItsAggregations(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(2, 1)
+
+	ctx, leave, err := lanes.Enter(context.Background(), LaneBulk)
+	require.NoError(t, err)
+	// the aggregation uses the slot held rather than waiting for another one
+	_, err = lanes.run(ctx, LaneBulk, func() (interface{}, error) {
+		expect.Equal(1, lanes.inFlight[LaneBulk])
+		return nil, nil
+	})
+	expect.NoError(err)
+
+	bulk := startLaneRun(lanes, LaneBulk)
+	expect.False(bulk.isStarted())
+	leave()
+	expect.True(bulk.isStarted())
+	bulk.finish(t)
+}
+
+func TestLanes_Concurrency(t *testing.T) {
+	expect := assert.New(t)
+	lanes := NewLanes(4, 2)
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight := map[Lane]int{}, map[Lane]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		lane := LaneInteractive
+		if i%2 == 0 {
+			lane = LaneBulk
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := lanes.run(context.Background(), lane, func() (interface{}, error) {
+				mutex.Lock()
+				inFlight[lane]++
+				if inFlight[lane] > maxInFlight[lane] {
+					maxInFlight[lane] = inFlight[lane]
+				}
+				expect.True(inFlight[LaneInteractive]+inFlight[LaneBulk] <= 4)
+				mutex.Unlock()
+				time.Sleep(time.Millisecond)
+				mutex.Lock()
+				inFlight[lane]--
+				mutex.Unlock()
+				return nil, nil
+			})
+			expect.NoError(err)
+		}()
+	}
+	wg.Wait()
+	expect.True(maxInFlight[LaneBulk] <= 2)
+}
+
+func TestAggregateSuggester_LanesShareCache(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"f758ef56-c40a-3162-91aa-3e8a3aabc495": london},
+		&stubSuggester{suggestions: []Suggestion{{Concept: london}}})
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+	aggregateSuggester.Lanes = NewLanes(1, 1)
+	latency := aggregateSuggester.Lanes.metrics[LaneInteractive].latency.Count()
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	bulk, err := aggregateSuggester.GetSuggestionsWithOptions(payload, "tid_test", SuggestionOptions{Lane: LaneBulk})
+	expect.NoError(err)
+	interactive, err := aggregateSuggester.GetSuggestionsWithOptions(payload, "tid_test", SuggestionOptions{Lane: LaneInteractive})
+	expect.NoError(err)
+
+	expect.Equal(bulk, interactive)
+	expect.Len(interactive.Suggestions, 1)
+	// the interactive request was served from the cache without an aggregation
+	expect.Equal(latency, aggregateSuggester.Lanes.metrics[LaneInteractive].latency.Count())
+}
+
+func TestParseLane(t *testing.T) {
+	lane, err := ParseLane("bulk")
+	assert.NoError(t, err)
+	assert.Equal(t, LaneBulk, lane)
+
+	_, err = ParseLane("batch")
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+var ErrDownstreamSaturated = errors.New("too many requests in progress to the service")
+
+// LimitedClient caps the number of requests in progress to a service. The requests over the cap wait for a while,
+// and fail with ErrDownstreamSaturated if none completes, so that a spike isn't passed on to the service.
+type LimitedClient struct {
+	// MaxWait is how long a request waits for another one to complete. 0 waits until the request is cancelled.
+	MaxWait time.Duration
+
+	client    Client
+	slots     chan struct{}
+	saturated metrics.Counter
+}
+
+// NewLimitedClient builds a client sending at most limit requests at once, counting the requests failing for lack of a slot in a metric named after the system.
+func NewLimitedClient(systemId string, limit int, client Client) *LimitedClient {
+	return &LimitedClient{
+		client:    client,
+		slots:     make(chan struct{}, limit),
+		saturated: metrics.GetOrRegisterCounter("downstream."+systemId+".saturated", metrics.DefaultRegistry),
+	}
+}
+
+func (c *LimitedClient) Do(req *http.Request) (*http.Response, error) {
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		var timeout <-chan time.Time
+		if c.MaxWait > 0 {
+			timer := time.NewTimer(c.MaxWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case c.slots <- struct{}{}:
+		case <-timeout:
+			c.saturated.Inc(1)
+			return nil, ErrDownstreamSaturated
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		<-c.slots
+		return resp, err
+	}
+	// the slot is held until the response is read
+	resp.Body = &slotReleasingBody{ReadCloser: resp.Body, release: func() { <-c.slots }}
+	return resp, nil
+}
+
+type slotReleasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *slotReleasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimitedClient(systemId string, limit int, client Client) *LimitedClient {
+	metrics.DefaultRegistry.Unregister("downstream." + systemId + ".saturated")
+	return NewLimitedClient(systemId, limit, client)
+}
+
+func okResponder(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func TestLimitedClient_HoldsSlotUntilBodyIsClosed(t *testing.T) {
+	expect := assert.New(t)
+	client := newTestLimitedClient("hold", 1, stubHttpClient(okResponder))
+	client.MaxWait = 10 * time.Millisecond
+
+	req, err := http.NewRequest("GET", "http://service/things", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	expect.Equal(ErrDownstreamSaturated, err)
+	expect.Equal(int64(1), client.saturated.Count())
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	expect.Equal("ok", string(body))
+	expect.NoError(resp.Body.Close())
+	expect.NoError(resp.Body.Close())
+
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	expect.Empty(client.slots)
+}
+
+func TestLimitedClient_WaitsForSlot(t *testing.T) {
+	expect := assert.New(t)
+	client := newTestLimitedClient("wait", 1, stubHttpClient(okResponder))
+
+	req, err := http.NewRequest("GET", "http://service/things", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		resp.Body.Close()
+	}()
+	second, err := client.Do(req)
+	require.NoError(t, err)
+	second.Body.Close()
+	expect.Equal(int64(0), client.saturated.Count())
+}
+
+func TestLimitedClient_StopsWaitingWhenCancelled(t *testing.T) {
+	client := newTestLimitedClient("cancelled", 1, stubHttpClient(okResponder))
+
+	req, err := http.NewRequest("GET", "http://service/things", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Do(req.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestLimitedClient_ReleasesSlotOnError(t *testing.T) {
+	client := newTestLimitedClient("error", 1, stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+
+	req, err := http.NewRequest("GET", "http://service/things", nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = client.Do(req)
+		assert.EqualError(t, err, "connection refused")
+	}
+	assert.Empty(t, client.slots)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	fp "path/filepath"
+)
+
+// SuggestionsMerger collapses the suggestions which different suggesters made for the same concorded concept,
+// keeping the position of the first one and recording every suggester which made it.
+type SuggestionsMerger struct {
+	predicatePriority map[string]int
+}
+
+// NewSuggestionsMerger builds a merger which resolves predicate conflicts by the given predicates, highest priority first.
+// Predicates which aren't listed rank below the listed ones, with the first suggestion winning between them.
+func NewSuggestionsMerger(predicatePriority []string) *SuggestionsMerger {
+	priority := make(map[string]int, len(predicatePriority))
+	for i, predicate := range predicatePriority {
+		if _, ok := priority[predicate]; !ok {
+			priority[predicate] = i
+		}
+	}
+	return &SuggestionsMerger{predicatePriority: priority}
+}
+
+func (m *SuggestionsMerger) Merge(suggestions []Suggestion) []Suggestion {
+	merged := make([]Suggestion, 0, len(suggestions))
+	positions := make(map[string]int, len(suggestions))
+	for _, suggestion := range suggestions {
+		id := fp.Base(suggestion.ID)
+		pos, ok := positions[id]
+		if !ok {
+			positions[id] = len(merged)
+			suggestion.Sources = unionSources(nil, suggestion.Sources)
+			merged = append(merged, suggestion)
+			continue
+		}
+
+		existing := &merged[pos]
+		if m.rank(suggestion.Predicate) < m.rank(existing.Predicate) {
+			existing.Predicate = suggestion.Predicate
+		}
+		existing.IsFTAuthor = existing.IsFTAuthor || suggestion.IsFTAuthor
+		if existing.Provenance == "" {
+			existing.Provenance = suggestion.Provenance
+		}
+		existing.Sources = unionSources(existing.Sources, suggestion.Sources)
+	}
+	return merged
+}
+
+func (m *SuggestionsMerger) rank(predicate string) int {
+	if rank, ok := m.predicatePriority[predicate]; ok {
+		return rank
+	}
+	return len(m.predicatePriority)
+}
+
+func unionSources(sources []string, others []string) []string {
+	for _, other := range others {
+		if !contains(sources, other) {
+			sources = append(sources, other)
+		}
+	}
+	return sources
+}
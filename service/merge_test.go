@@ -0,0 +1,92 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const predicateAbout = "http://www.ft.com/ontology/annotation/about"
+
+func TestSuggestionsMerger_Merge(t *testing.T) {
+	merger := NewSuggestionsMerger([]string{predicateHasAuthor, predicateAbout, predicateMentions})
+
+	summers := Concept{ID: "http://www.ft.com/thing/9a5e3b4a-55da-498c-816f-9c534e1392b6", PrefLabel: "Lawrence Summers", Type: ontologyPersonType}
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	apple := Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55", PrefLabel: "Apple", Type: ontologyOrganisationType}
+
+	testCases := []struct {
+		name        string
+		suggestions []Suggestion
+		expected    []Suggestion
+	}{
+		{
+			name:        "empty",
+			suggestions: []Suggestion{},
+			expected:    []Suggestion{},
+		},
+		{
+			name: "noDuplicates",
+			suggestions: []Suggestion{
+				{Concept: london, Predicate: predicateMentions, Sources: []string{"Ontotext Suggestion API"}},
+				{Concept: apple, Predicate: predicateAbout, Sources: []string{"Ontotext Suggestion API"}},
+			},
+			expected: []Suggestion{
+				{Concept: london, Predicate: predicateMentions, Sources: []string{"Ontotext Suggestion API"}},
+				{Concept: apple, Predicate: predicateAbout, Sources: []string{"Ontotext Suggestion API"}},
+			},
+		},
+		{
+			name: "higherPriorityPredicateWins",
+			suggestions: []Suggestion{
+				{Concept: london, Predicate: predicateMentions, Sources: []string{"Ontotext Suggestion API"}},
+				{Concept: summers, Predicate: predicateAbout, Sources: []string{"Ontotext Suggestion API"}},
+				{Concept: Concept{ID: summers.ID, PrefLabel: summers.PrefLabel, Type: summers.Type, IsFTAuthor: true}, Predicate: predicateHasAuthor, Sources: []string{"Authors Suggestion API"}},
+			},
+			expected: []Suggestion{
+				{Concept: london, Predicate: predicateMentions, Sources: []string{"Ontotext Suggestion API"}},
+				{Concept: Concept{ID: summers.ID, PrefLabel: summers.PrefLabel, Type: summers.Type, IsFTAuthor: true}, Predicate: predicateHasAuthor, Sources: []string{"Ontotext Suggestion API", "Authors Suggestion API"}},
+			},
+		},
+		{
+			name: "firstPredicateKeptWhenUnranked",
+			suggestions: []Suggestion{
+				{Concept: apple, Predicate: "http://www.ft.com/ontology/annotation/unranked", Sources: []string{"first"}},
+				{Concept: apple, Sources: []string{"second"}},
+				{Concept: apple, Sources: []string{"first"}},
+			},
+			expected: []Suggestion{
+				{Concept: apple, Predicate: "http://www.ft.com/ontology/annotation/unranked", Sources: []string{"first", "second"}},
+			},
+		},
+		{
+			name: "matchedOnUUID",
+			suggestions: []Suggestion{
+				{Concept: apple, Predicate: predicateMentions, Sources: []string{"first"}},
+				{Concept: Concept{ID: "http://api.ft.com/things/9332270e-f959-3f55-9153-d30acd0d0a55"}, Predicate: predicateAbout, Sources: []string{"second"}},
+			},
+			expected: []Suggestion{
+				{Concept: apple, Predicate: predicateAbout, Sources: []string{"first", "second"}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, merger.Merge(testCase.suggestions))
+		})
+	}
+}
+
+func TestSuggestionsMerger_MergeDoesNotModifyInput(t *testing.T) {
+	merger := NewSuggestionsMerger(nil)
+	apple := Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55"}
+	suggestions := []Suggestion{
+		{Concept: apple, Sources: []string{"first"}},
+		{Concept: apple, Sources: []string{"second"}},
+	}
+
+	merger.Merge(suggestions)
+
+	assert.Equal(t, []string{"first"}, suggestions[0].Sources)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-fthealth/v1_1"
+)
+
+var (
+	ErrBlacklistEntryWithoutUUID   = errors.New("blacklist entry has no uuid")
+	ErrBlacklistEntryWithoutReason = errors.New("blacklist entry has no reason")
+)
+
+// BlacklistOverlay is a local blacklist, for vetoing concepts urgently when the remote blacklist is unavailable or slow to change.
+// The entries are persisted to a file, unless its path is empty.
+type BlacklistOverlay struct {
+	path string
+	now  func() time.Time
+
+	mutex     sync.RWMutex
+	entries   map[string]BlacklistEntry
+	listeners []func()
+}
+
+// NewBlacklistOverlay builds an overlay with the entries of the given file, if it exists.
+func NewBlacklistOverlay(path string) (*BlacklistOverlay, error) {
+	overlay := &BlacklistOverlay{
+		path:    path,
+		now:     time.Now,
+		entries: map[string]BlacklistEntry{},
+	}
+	if path == "" {
+		return overlay, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return overlay, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []BlacklistEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		overlay.entries[entry.UUID] = entry
+	}
+	return overlay, nil
+}
+
+// OnChange registers a function called after each change of the entries, e.g. to purge the suggestions cached before a veto.
+func (o *BlacklistOverlay) OnChange(listener func()) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.listeners = append(o.listeners, listener)
+}
+
+func (o *BlacklistOverlay) changed() {
+	for _, listener := range o.listeners {
+		listener()
+	}
+}
+
+// List returns the entries which haven't expired, by UUID.
+func (o *BlacklistOverlay) List() []BlacklistEntry {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	now := o.now()
+	entries := []BlacklistEntry{}
+	for _, entry := range o.entries {
+		if !entry.expired(now) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UUID < entries[j].UUID })
+	return entries
+}
+
+// Add records the entry, replacing any entry for the same UUID, and persists the overlay.
+func (o *BlacklistOverlay) Add(entry BlacklistEntry) (BlacklistEntry, error) {
+	if entry.UUID == "" {
+		return entry, ErrBlacklistEntryWithoutUUID
+	}
+	if entry.Reason == "" {
+		return entry, ErrBlacklistEntryWithoutReason
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	entry.CreatedAt = o.now().UTC()
+	previous, existed := o.entries[entry.UUID]
+	o.entries[entry.UUID] = entry
+	if err := o.save(); err != nil {
+		if existed {
+			o.entries[entry.UUID] = previous
+		} else {
+			delete(o.entries, entry.UUID)
+		}
+		return entry, err
+	}
+	o.changed()
+	return entry, nil
+}
+
+// Remove deletes the entry of the UUID and persists the overlay. It reports whether there was such an entry.
+func (o *BlacklistOverlay) Remove(uuid string) (bool, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	entry, ok := o.entries[uuid]
+	if !ok {
+		return false, nil
+	}
+	delete(o.entries, uuid)
+	if err := o.save(); err != nil {
+		o.entries[uuid] = entry
+		return false, err
+	}
+	o.changed()
+	return true, nil
+}
+
+// UUIDs returns the UUIDs of the entries which haven't expired.
+func (o *BlacklistOverlay) UUIDs() []string {
+	var uuids []string
+	for _, entry := range o.List() {
+		uuids = append(uuids, entry.UUID)
+	}
+	return uuids
+}
+
+// save writes all the entries to a temporary file which then replaces the overlay file, so a failed write leaves the previous file intact.
+func (o *BlacklistOverlay) save() error {
+	if o.path == "" {
+		return nil
+	}
+
+	entries := make([]BlacklistEntry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UUID < entries[j].UUID })
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(o.path), filepath.Base(o.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), o.path)
+}
+
+// OverlayBlacklister adds the entries of a local overlay to the blacklist of another blacklister.
+// The overlay still applies when the other blacklister fails.
+type OverlayBlacklister struct {
+	remote  ConceptBlacklister
+	overlay *BlacklistOverlay
+}
+
+func NewOverlayBlacklister(remote ConceptBlacklister, overlay *BlacklistOverlay) ConceptBlacklister {
+	return &OverlayBlacklister{
+		remote:  remote,
+		overlay: overlay,
+	}
+}
+
+func (b *OverlayBlacklister) IsBlacklisted(conceptId string, bl Blacklist) bool {
+	return b.remote.IsBlacklisted(conceptId, bl)
+}
+
+func (b *OverlayBlacklister) GetBlacklist(tid string) (Blacklist, error) {
+	remote, err := b.remote.GetBlacklist(tid)
+	blacklist := Blacklist{UUIDS: remote.UUIDS, Entries: append([]BlacklistEntry{}, remote.Entries...)}
+	blacklist.Entries = append(blacklist.Entries, b.overlay.List()...)
+	return blacklist, err
+}
+
+func (b *OverlayBlacklister) Check() v1_1.Check {
+	return b.remote.Check()
+}
+
+func (b *OverlayBlacklister) Probe() (string, error) {
+	remote, ok := b.remote.(prober)
+	if !ok {
+		return "", errNotProbed
+	}
+	return remote.Probe()
+}
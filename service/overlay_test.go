@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOverlayFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "overlay")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "blacklist.json")
+}
+
+func TestBlacklistOverlay_AddRemoveAndReload(t *testing.T) {
+	expect := assert.New(t)
+	path := newTestOverlayFile(t)
+
+	overlay, err := NewBlacklistOverlay(path)
+	require.NoError(t, err)
+	expect.Empty(overlay.List())
+
+	entry, err := overlay.Add(BlacklistEntry{UUID: "id2", Reason: "legal", Author: "editor"})
+	require.NoError(t, err)
+	expect.False(entry.CreatedAt.IsZero())
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "spam"})
+	require.NoError(t, err)
+
+	reloaded, err := NewBlacklistOverlay(path)
+	require.NoError(t, err)
+	expect.Equal([]string{"id1", "id2"}, reloaded.UUIDs())
+	expect.Equal("editor", reloaded.List()[1].Author)
+
+	removed, err := reloaded.Remove("id1")
+	require.NoError(t, err)
+	expect.True(removed)
+	removed, err = reloaded.Remove("id1")
+	require.NoError(t, err)
+	expect.False(removed)
+
+	reloaded, err = NewBlacklistOverlay(path)
+	require.NoError(t, err)
+	expect.Equal([]string{"id2"}, reloaded.UUIDs())
+}
+
+func TestBlacklistOverlay_InMemory(t *testing.T) {
+	expect := assert.New(t)
+
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "spam"})
+	require.NoError(t, err)
+
+	expect.Equal([]string{"id1"}, overlay.UUIDs())
+}
+
+func TestBlacklistOverlay_InvalidFile(t *testing.T) {
+	path := newTestOverlayFile(t)
+	require.NoError(t, ioutil.WriteFile(path, []byte("not json"), 0644))
+
+	_, err := NewBlacklistOverlay(path)
+	assert.Error(t, err)
+}
+
+func TestBlacklistOverlay_InvalidEntries(t *testing.T) {
+	expect := assert.New(t)
+
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+
+	_, err = overlay.Add(BlacklistEntry{Reason: "spam"})
+	expect.Equal(ErrBlacklistEntryWithoutUUID, err)
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1"})
+	expect.Equal(ErrBlacklistEntryWithoutReason, err)
+	expect.Empty(overlay.List())
+}
+
+func TestBlacklistOverlay_ExpiredEntries(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+	overlay.now = func() time.Time { return now }
+
+	expiry := now.Add(time.Hour)
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "embargo", ExpiresAt: &expiry})
+	require.NoError(t, err)
+	_, err = overlay.Add(BlacklistEntry{UUID: "id2", Reason: "legal"})
+	require.NoError(t, err)
+	expect.Equal([]string{"id1", "id2"}, overlay.UUIDs())
+
+	now = expiry
+	expect.Equal([]string{"id2"}, overlay.UUIDs())
+}
+
+func TestBlacklistOverlay_SaveErrorRollsBack(t *testing.T) {
+	expect := assert.New(t)
+
+	overlay, err := NewBlacklistOverlay(filepath.Join(newTestOverlayFile(t), "missing", "blacklist.json"))
+	require.NoError(t, err)
+
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "spam"})
+	expect.Error(err)
+	expect.Empty(overlay.List())
+}
+
+func TestOverlayBlacklister_GetBlacklist(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"uuids":["remote-id"]}`)), StatusCode: http.StatusOK}, nil)
+
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+	_, err = overlay.Add(BlacklistEntry{UUID: "local-id", Reason: "spam"})
+	require.NoError(t, err)
+
+	blacklister := NewOverlayBlacklister(NewConceptBlacklister("http://test-url", "/blacklist", mockClient), overlay)
+	blacklist, err := blacklister.GetBlacklist("tid_test")
+
+	expect.NoError(err)
+	expect.Equal([]string{"remote-id"}, blacklist.UUIDS)
+	require.Len(t, blacklist.Entries, 1)
+	expect.Equal("local-id", blacklist.Entries[0].UUID)
+	expect.Equal("spam", blacklist.Entries[0].Reason)
+	expect.True(blacklister.IsBlacklisted("local-id", blacklist))
+	expect.True(blacklister.IsBlacklisted("remote-id", blacklist))
+}
+
+func TestOverlayBlacklister_GetBlacklistWhenRemoteFails(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{}, errors.New("connection refused"))
+
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+	_, err = overlay.Add(BlacklistEntry{UUID: "local-id", Reason: "spam"})
+	require.NoError(t, err)
+
+	blacklister := NewOverlayBlacklister(NewConceptBlacklister("http://test-url", "/blacklist", mockClient), overlay)
+	blacklist, err := blacklister.GetBlacklist("tid_test")
+
+	expect.EqualError(err, "connection refused")
+	expect.Empty(blacklist.UUIDS)
+	require.Len(t, blacklist.Entries, 1)
+	expect.Equal("local-id", blacklist.Entries[0].UUID)
+}
+
+func TestBlacklistOverlay_VetoPurgesCachedSuggestions(t *testing.T) {
+	expect := assert.New(t)
+
+	london := Concept{ID: "http://www.ft.com/thing/london-uuid", PrefLabel: "London", Type: ontologyLocationType}
+	suggester := &stubSuggester{suggestions: []Suggestion{{Concept: london}}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{"london-uuid": london}, suggester)
+	overlay, err := NewBlacklistOverlay("")
+	require.NoError(t, err)
+	aggregateSuggester.Blacklister = NewOverlayBlacklister(aggregateSuggester.Blacklister, overlay)
+	aggregateSuggester.Cache = NewSuggestionsCache(10, time.Minute)
+	overlay.OnChange(aggregateSuggester.Cache.Purge)
+
+	payload := []byte(`{"bodyXML":"<body>London</body>"}`)
+	response, err := aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london}}, response.Suggestions)
+
+	_, err = overlay.Add(BlacklistEntry{UUID: "london-uuid", Reason: "legal"})
+	require.NoError(t, err)
+
+	response, err = aggregateSuggester.GetSuggestions(payload, "tid_test")
+	expect.NoError(err)
+	expect.Empty(response.Suggestions)
+}
+
+func TestBlacklistOverlay_OnChangeOnlyAfterChanges(t *testing.T) {
+	expect := assert.New(t)
+
+	overlay, err := NewBlacklistOverlay(filepath.Join(newTestOverlayFile(t), "missing", "blacklist.json"))
+	require.NoError(t, err)
+	changes := 0
+	overlay.OnChange(func() { changes++ })
+
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "spam"})
+	expect.Error(err)
+	removed, err := overlay.Remove("id1")
+	expect.NoError(err)
+	expect.False(removed)
+	expect.Equal(0, changes)
+
+	overlay, err = NewBlacklistOverlay("")
+	require.NoError(t, err)
+	overlay.OnChange(func() { changes++ })
+	_, err = overlay.Add(BlacklistEntry{UUID: "id1", Reason: "spam"})
+	require.NoError(t, err)
+	_, err = overlay.Remove("id1")
+	require.NoError(t, err)
+	expect.Equal(2, changes)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	fp "path/filepath"
+	"regexp"
+)
+
+// ProvenanceRule labels the suggestions made by suggestion rules rather than by a suggestion API.
+const ProvenanceRule = "rule"
+
+// SuggestionRule suggests its concept whenever one of its keywords or patterns appears in the title or body of the content.
+// Keywords match whole words regardless of case, patterns are regular expressions.
+type SuggestionRule struct {
+	Name      string   `json:"name"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Patterns  []string `json:"patterns,omitempty"`
+	Concept   Concept  `json:"concept"`
+	Predicate string   `json:"predicate,omitempty"`
+}
+
+type compiledRule struct {
+	SuggestionRule
+	matchers []*regexp.Regexp
+}
+
+func (r compiledRule) matches(texts ...string) bool {
+	for _, matcher := range r.matchers {
+		for _, text := range texts {
+			if matcher.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RulesSuggester suggests the concepts of the rules matching the content, with the rule provenance.
+// Placed before the other suggesters, it also boosts the concepts they suggest to the top of the merged suggestions.
+type RulesSuggester struct {
+	name  string
+	rules []compiledRule
+}
+
+// LoadSuggestionRules reads the JSON array of rules in the given file.
+func LoadSuggestionRules(path string) ([]SuggestionRule, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []SuggestionRule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func NewRulesSuggester(rules []SuggestionRule) (*RulesSuggester, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Concept.ID == "" {
+			return nil, fmt.Errorf("suggestion rule %q has no concept id", rule.Name)
+		}
+		if len(rule.Keywords) == 0 && len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("suggestion rule %q has neither keywords nor patterns", rule.Name)
+		}
+
+		c := compiledRule{SuggestionRule: rule}
+		for _, keyword := range rule.Keywords {
+			c.matchers = append(c.matchers, regexp.MustCompile(`(?i)(?:^|[^\pL\pN_])`+regexp.QuoteMeta(keyword)+`(?:[^\pL\pN_]|$)`))
+		}
+		for _, pattern := range rule.Patterns {
+			matcher, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("suggestion rule %q has an invalid pattern: %w", rule.Name, err)
+			}
+			c.matchers = append(c.matchers, matcher)
+		}
+		compiled = append(compiled, c)
+	}
+	return &RulesSuggester{name: "Suggestion Rules", rules: compiled}, nil
+}
+
+// GetSuggestions matches the rules against the cleaned title and body of the payload.
+func (r *RulesSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	var input JsonInput
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return SuggestionsResponse{}, err
+	}
+
+	response := SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	seen := make(map[string]bool)
+	for _, rule := range r.rules {
+		if seen[rule.Concept.ID] || !rule.matches(input.Headline, input.Body) {
+			continue
+		}
+		seen[rule.Concept.ID] = true
+		response.Suggestions = append(response.Suggestions, Suggestion{
+			Concept:    rule.Concept,
+			Predicate:  rule.Predicate,
+			Provenance: ProvenanceRule,
+		})
+	}
+	return response, nil
+}
+
+func (r *RulesSuggester) FilterSuggestions(suggestions []Suggestion) []Suggestion {
+	return suggestions
+}
+
+func (r *RulesSuggester) GetName() string {
+	return r.name
+}
+
+// boostRuleConcepts moves the suggestions of the concepts suggested by rules before the other suggestions, keeping their order,
+// whichever suggester made them and whether or not they were merged.
+func boostRuleConcepts(suggestions []Suggestion) []Suggestion {
+	ruled := make(map[string]bool)
+	for _, suggestion := range suggestions {
+		if suggestion.Provenance == ProvenanceRule {
+			ruled[fp.Base(suggestion.ID)] = true
+		}
+	}
+	if len(ruled) == 0 {
+		return suggestions
+	}
+
+	boosted := make([]Suggestion, 0, len(suggestions))
+	var others []Suggestion
+	for _, suggestion := range suggestions {
+		if ruled[fp.Base(suggestion.ID)] {
+			boosted = append(boosted, suggestion)
+		} else {
+			others = append(others, suggestion)
+		}
+	}
+	return append(boosted, others...)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	lunchWithTheFT = Concept{ID: "http://www.ft.com/thing/lunch-uuid", PrefLabel: "Lunch with the FT", Type: ontologyTopicType}
+	houseAndHome   = Concept{ID: "http://www.ft.com/thing/house-uuid", PrefLabel: "House & Home", Type: ontologyTopicType}
+)
+
+func TestRulesSuggester_GetSuggestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected []Suggestion
+	}{
+		{
+			name:     "keyword in title",
+			payload:  `{"title":"Lunch with the FT: a chef","bodyXML":"text"}`,
+			expected: []Suggestion{{Concept: lunchWithTheFT, Predicate: "http://www.ft.com/ontology/annotation/about", Provenance: ProvenanceRule}},
+		},
+		{
+			name:     "keyword in body regardless of case",
+			payload:  `{"bodyXML":"We had LUNCH WITH THE FT yesterday."}`,
+			expected: []Suggestion{{Concept: lunchWithTheFT, Predicate: "http://www.ft.com/ontology/annotation/about", Provenance: ProvenanceRule}},
+		},
+		{
+			name:     "keyword within a word",
+			payload:  `{"bodyXML":"brunch with the ftse"}`,
+			expected: []Suggestion{},
+		},
+		{
+			name:     "pattern",
+			payload:  `{"bodyXML":"The property market in London"}`,
+			expected: []Suggestion{{Concept: houseAndHome, Provenance: ProvenanceRule}},
+		},
+		{
+			name:    "several rules suggest each concept once",
+			payload: `{"title":"Lunch with the FT","bodyXML":"property prices and lunch with the FT"}`,
+			expected: []Suggestion{
+				{Concept: lunchWithTheFT, Predicate: "http://www.ft.com/ontology/annotation/about", Provenance: ProvenanceRule},
+				{Concept: houseAndHome, Provenance: ProvenanceRule},
+			},
+		},
+	}
+
+	suggester, err := NewRulesSuggester([]SuggestionRule{
+		{Name: "lunch", Keywords: []string{"Lunch with the FT"}, Concept: lunchWithTheFT, Predicate: "http://www.ft.com/ontology/annotation/about"},
+		{Name: "house", Patterns: []string{`propert(y|ies)`}, Concept: houseAndHome},
+		{Name: "lunch again", Keywords: []string{"lunch"}, Concept: lunchWithTheFT},
+	})
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := suggester.GetSuggestions([]byte(test.payload), "tid_test")
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, response.Suggestions)
+		})
+	}
+}
+
+func TestBoostRuleConcepts(t *testing.T) {
+	london := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/london-uuid"}}
+	paris := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/paris-uuid"}}
+	property := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/property-uuid"}}
+	ruledProperty := Suggestion{Concept: property.Concept, Provenance: ProvenanceRule}
+	series := Suggestion{Concept: Concept{ID: "http://www.ft.com/thing/series-uuid"}, Provenance: ProvenanceRule}
+
+	assert.Equal(t, []Suggestion{london, paris}, boostRuleConcepts([]Suggestion{london, paris}))
+	assert.Equal(t, []Suggestion{property, ruledProperty, series, london, paris},
+		boostRuleConcepts([]Suggestion{london, property, paris, ruledProperty, series}))
+}
+
+func TestNewRulesSuggester_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  SuggestionRule
+		error string
+	}{
+		{"no concept", SuggestionRule{Name: "r", Keywords: []string{"k"}}, `suggestion rule "r" has no concept id`},
+		{"no keywords", SuggestionRule{Name: "r", Concept: houseAndHome}, `suggestion rule "r" has neither keywords nor patterns`},
+		{"invalid pattern", SuggestionRule{Name: "r", Patterns: []string{"("}, Concept: houseAndHome}, "suggestion rule \"r\" has an invalid pattern: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewRulesSuggester([]SuggestionRule{test.rule})
+			assert.EqualError(t, err, test.error)
+		})
+	}
+}
+
+func TestLoadSuggestionRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"name":"lunch","keywords":["Lunch with the FT"],"concept":{"id":"http://www.ft.com/thing/lunch-uuid","prefLabel":"Lunch with the FT","type":"http://www.ft.com/ontology/Topic"}}]`), 0644))
+
+	rules, err := LoadSuggestionRules(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SuggestionRule{{Name: "lunch", Keywords: []string{"Lunch with the FT"}, Concept: lunchWithTheFT}}, rules)
+
+	_, err = LoadSuggestionRules(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
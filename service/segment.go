@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Segmentation splits the cleaned body of big content into chunks, which are sent to the suggesters separately
+// so that none is sent more than it handles well. The suggestions made for the chunks are merged.
+type Segmentation struct {
+	// ChunkSize is the size in bytes of the body above which it is split, and the size the chunks don't exceed.
+	ChunkSize int
+	// MaxChunks truncates the body past that many chunks. 0 keeps all of it.
+	MaxChunks int
+	// Concurrency is the number of chunks of a request sent to a suggester at once. 0 sends them all at once.
+	Concurrency int
+
+	segmented metrics.Counter
+	truncated metrics.Counter
+}
+
+func NewSegmentation(chunkSize int, maxChunks int) *Segmentation {
+	return &Segmentation{
+		ChunkSize: chunkSize,
+		MaxChunks: maxChunks,
+		segmented: metrics.GetOrRegisterCounter("segmentation.segmented", metrics.DefaultRegistry),
+		truncated: metrics.GetOrRegisterCounter("segmentation.truncated", metrics.DefaultRegistry),
+	}
+}
+
+// split returns the payloads of the chunks of the transformed payload, each with the title and byline of the content,
+// or nil when the body doesn't need splitting. It also tells whether the body was truncated.
+func (s *Segmentation) split(data []byte) ([][]byte, bool) {
+	var input JsonInput
+	if err := json.Unmarshal(data, &input); err != nil || len(input.Body) <= s.ChunkSize {
+		return nil, false
+	}
+
+	bodies := splitText(input.Body, s.ChunkSize)
+	truncated := s.MaxChunks > 0 && len(bodies) > s.MaxChunks
+	if truncated {
+		bodies = bodies[:s.MaxChunks]
+		s.truncated.Inc(1)
+	}
+	s.segmented.Inc(1)
+
+	chunks := make([][]byte, 0, len(bodies))
+	for _, body := range bodies {
+		input.Body = body
+		//ignoring marshalling errors as neither UnsupportedTypeError nor UnsupportedValueError is possible
+		chunk, _ := json.Marshal(input)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, truncated
+}
+
+// splitText splits the text in chunks of at most size bytes, preferably after the end of a sentence, or else between words.
+func splitText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		end := strings.LastIndex(text[:size], ". ")
+		if end > 0 {
+			end++
+		} else if end = strings.LastIndexByte(text[:size], ' '); end <= 0 {
+			// no word boundary, cut at a rune boundary
+			end = size
+			for end > 0 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:end]))
+		text = strings.TrimSpace(text[end:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// bylineSuggester is implemented by the suggesters which only suggest from the byline, e.g. the authors suggesters.
+// As all the chunks have the whole byline, they are only sent the first chunk.
+type bylineSuggester interface {
+	suggestsFromByline() bool
+}
+
+func suggestsFromByline(suggester Suggester) bool {
+	b, ok := suggester.(bylineSuggester)
+	return ok && b.suggestsFromByline()
+}
+
+// suggest gets the suggestions of the delegate for the chunks, up to the concurrency at once, and merges them in the order of the chunks.
+// The delegate fails if it fails for any chunk, and has no content only if it has none for all the chunks.
+func (s *Segmentation) suggest(delegate Suggester, chunks [][]byte, tid string) (SuggestionsResponse, error) {
+	if suggestsFromByline(delegate) {
+		return delegate.GetSuggestions(chunks[0], tid)
+	}
+
+	concurrency := s.Concurrency
+	if concurrency <= 0 || concurrency > len(chunks) {
+		concurrency = len(chunks)
+	}
+	slots := make(chan struct{}, concurrency)
+	responses := make([]SuggestionsResponse, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, chunk []byte) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			responses[i], errs[i] = delegate.GetSuggestions(chunk, tid)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	merged := SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	seen := map[string]bool{}
+	var noContent error
+	for i, resp := range responses {
+		if err := errs[i]; err != nil {
+			if !errors.Is(err, NoContentError) {
+				return merged, err
+			}
+			noContent = err
+			continue
+		}
+		for _, suggestion := range resp.Suggestions {
+			key := suggestion.ID + " " + suggestion.Predicate
+			if !seen[key] {
+				seen[key] = true
+				merged.Suggestions = append(merged.Suggestions, suggestion)
+			}
+		}
+		for _, degraded := range resp.Degraded {
+			if !contains(merged.Degraded, degraded) {
+				merged.Degraded = append(merged.Degraded, degraded)
+			}
+		}
+	}
+	if noContent != nil && len(merged.Suggestions) == 0 {
+		return merged, noContent
+	}
+	return merged, nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitText(t *testing.T) {
+	expect := assert.New(t)
+
+	expect.Equal([]string{"First sentence.", "Second one.", "Third."}, splitText("First sentence. Second one. Third.", 16))
+	// sentences longer than a chunk are split between words
+	expect.Equal([]string{"A very long", "sentence. Next."}, splitText("A very long sentence. Next.", 16))
+	// words longer than a chunk are cut, between runes
+	expect.Equal([]string{"Zürich", "Zürich"}, splitText("ZürichZürich", 7))
+	expect.Equal([]string{"short"}, splitText("short", 16))
+
+	text := "Lorem ipsum dolor sit amet. Consectetur adipiscing elit, sed do eiusmod. Tempor incididunt ut labore. Et dolore magna aliqua."
+	for _, size := range []int{12, 30, 50} {
+		chunks := splitText(text, size)
+		for _, chunk := range chunks {
+			expect.True(len(chunk) <= size, chunk)
+		}
+		expect.Equal(strings.Fields(text), strings.Fields(strings.Join(chunks, " ")))
+	}
+}
+
+func TestSegmentation_Split(t *testing.T) {
+	expect := assert.New(t)
+	segmentation := NewSegmentation(16, 2)
+
+	chunks, truncated := segmentation.split([]byte(`{"title":"Title","byline":"By Author","bodyXML":"Short body."}`))
+	expect.Nil(chunks)
+	expect.False(truncated)
+
+	chunks, truncated = segmentation.split([]byte(`{"title":"Title","byline":"By Author","bodyXML":"First sentence. Second one. Third."}`))
+	expect.True(truncated)
+	require.Len(t, chunks, 2)
+	for i, body := range []string{"First sentence.", "Second one."} {
+		var input JsonInput
+		require.NoError(t, json.Unmarshal(chunks[i], &input))
+		expect.Equal(JsonInput{Headline: "Title", Byline: "By Author", Body: body}, input)
+	}
+
+	segmentation.MaxChunks = 0
+	chunks, truncated = segmentation.split([]byte(`{"bodyXML":"First sentence. Second one. Third."}`))
+	expect.False(truncated)
+	expect.Len(chunks, 3)
+}
+
+// chunkSuggester suggests the concepts whose prefLabel is in the body of each chunk, recording the chunks.
+type chunkSuggester struct {
+	stubSuggester
+	concepts []Concept
+	err      error
+
+	// delay holds each request, so that the concurrent requests overlap
+	delay time.Duration
+
+	mutex       sync.Mutex
+	bodies      []string
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *chunkSuggester) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	var input JsonInput
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return SuggestionsResponse{}, err
+	}
+	c.mutex.Lock()
+	c.bodies = append(c.bodies, input.Body)
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mutex.Unlock()
+	time.Sleep(c.delay)
+	c.mutex.Lock()
+	c.inFlight--
+	c.mutex.Unlock()
+
+	if c.err != nil && strings.Contains(input.Body, "fail") {
+		return SuggestionsResponse{}, c.err
+	}
+	resp := SuggestionsResponse{Suggestions: make([]Suggestion, 0)}
+	for _, concept := range c.concepts {
+		if strings.Contains(input.Body, concept.PrefLabel) {
+			resp.Suggestions = append(resp.Suggestions, Suggestion{Concept: concept, Predicate: predicateMentions})
+		}
+	}
+	if len(resp.Suggestions) == 0 {
+		return resp, NoContentError
+	}
+	return resp, nil
+}
+
+func TestSegmentation_Suggest(t *testing.T) {
+	expect := assert.New(t)
+	segmentation := NewSegmentation(20, 0)
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London"}
+	paris := Concept{ID: "http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997", PrefLabel: "Paris"}
+	suggester := &chunkSuggester{concepts: []Concept{london, paris}}
+	chunk := func(body string) []byte {
+		return []byte(`{"bodyXML":"` + body + `"}`)
+	}
+
+	resp, err := segmentation.suggest(suggester, [][]byte{chunk("London."), chunk("Nothing."), chunk("Paris and London.")}, "tid_test")
+	expect.NoError(err)
+	expect.Equal([]Suggestion{{Concept: london, Predicate: predicateMentions}, {Concept: paris, Predicate: predicateMentions}}, resp.Suggestions)
+	expect.ElementsMatch([]string{"London.", "Nothing.", "Paris and London."}, suggester.bodies)
+
+	_, err = segmentation.suggest(suggester, [][]byte{chunk("Nothing."), chunk("Nothing either.")}, "tid_test")
+	expect.Equal(NoContentError, err)
+
+	suggester.err = errors.New("suggestion API unavailable")
+	_, err = segmentation.suggest(suggester, [][]byte{chunk("London."), chunk("fail")}, "tid_test")
+	expect.EqualError(err, "suggestion API unavailable")
+}
+
+func chunkPayloads(bodies ...string) [][]byte {
+	var chunks [][]byte
+	for _, body := range bodies {
+		chunks = append(chunks, []byte(`{"byline":"Eric Platt","bodyXML":"`+body+`"}`))
+	}
+	return chunks
+}
+
+func TestSegmentation_SuggestBoundsConcurrency(t *testing.T) {
+	expect := assert.New(t)
+	segmentation := NewSegmentation(20, 0)
+	segmentation.Concurrency = 2
+	suggester := &chunkSuggester{delay: 10 * time.Millisecond}
+
+	_, err := segmentation.suggest(suggester, chunkPayloads("One.", "Two.", "Three.", "Four.", "Five.", "Six."), "tid_test")
+	expect.Equal(NoContentError, err)
+	expect.Len(suggester.bodies, 6)
+	expect.Equal(2, suggester.maxInFlight)
+}
+
+type bylineChunkSuggester struct {
+	*chunkSuggester
+}
+
+func (b bylineChunkSuggester) suggestsFromByline() bool {
+	return true
+}
+
+func TestSegmentation_SuggestFromBylineOnce(t *testing.T) {
+	expect := assert.New(t)
+	segmentation := NewSegmentation(20, 0)
+	suggester := bylineChunkSuggester{&chunkSuggester{}}
+
+	_, err := segmentation.suggest(suggester, chunkPayloads("One.", "Two.", "Three."), "tid_test")
+	expect.Equal(NoContentError, err)
+	expect.Equal([]string{"One."}, suggester.bodies)
+
+	bylineAuthors, err := NewBylineAuthorsSuggester(nil, nil)
+	require.NoError(t, err)
+	authors := NewAuthorsSuggester("", "", nil)
+	expect.True(suggestsFromByline(NewFallbackSuggester(bylineAuthors.CrossChecked(authors), bylineAuthors)))
+	expect.False(suggestsFromByline(NewFallbackSuggester(NewOntotextSuggester("", "", nil), bylineAuthors)))
+}
+
+func TestAggregateSuggester_SegmentsBigBodies(t *testing.T) {
+	expect := assert.New(t)
+	london := Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: ontologyLocationType}
+	paris := Concept{ID: "http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997", PrefLabel: "Paris", Type: ontologyLocationType}
+	suggester := &chunkSuggester{concepts: []Concept{london, paris}}
+	aggregateSuggester := newTestAggregateSuggester(t, map[string]Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+		"a1a2f869-dd01-11e8-abd7-6c96cfdf3997": paris,
+	}, suggester)
+	aggregateSuggester.Segmentation = NewSegmentation(20, 2)
+
+	resp, err := aggregateSuggester.GetSuggestions([]byte(`{"bodyXML":"<body><p>News from London.</p> <p>News from Paris.</p> <p>More news.</p></body>"}`), "tid_test")
+	expect.NoError(err)
+	expect.ElementsMatch([]string{"News from London.", "News from Paris."}, suggester.bodies)
+	expect.Len(resp.Suggestions, 2)
+	expect.Empty(resp.Degraded)
+}
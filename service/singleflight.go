@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// flightGroup collapses concurrent calls with the same key into a single execution whose result is shared by all the callers.
+// The zero value is ready to use. Shared results must be treated as read-only by the callers.
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// do executes fn, unless a call with the same key is already in flight, in which case it waits for that call and returns its result.
+// The returned flag reports whether the result was produced by another caller. A panic of fn is recovered and returned as the error of all the callers.
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		call.wg.Wait()
+		return call.value, call.err, true
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		call.wg.Done()
+	}()
+	call.value, call.err = g.call(fn)
+	return call.value, call.err, false
+}
+
+func (g *flightGroup) call(fn func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("in-flight call panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// idsKey builds an order independent key for a set of IDs.
+func idsKey(ids []string) string {
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
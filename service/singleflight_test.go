@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightGroup_CollapsesConcurrentCalls(t *testing.T) {
+	expect := assert.New(t)
+
+	var group flightGroup
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	fn := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	shared := make([]bool, 5)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, shared[0] = group.do("key", fn)
+	}()
+	<-started
+
+	for i := 1; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, shared[i] = group.do("key", fn)
+		}(i)
+	}
+	// give the other callers time to join the in-flight call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	expect.Equal(int32(1), atomic.LoadInt32(&calls))
+	for i := range results {
+		expect.Equal("result", results[i])
+	}
+	expect.False(shared[0])
+	expect.True(shared[1])
+}
+
+func TestFlightGroup_SharesErrors(t *testing.T) {
+	expect := assert.New(t)
+
+	var group flightGroup
+	_, err, shared := group.do("key", func() (interface{}, error) {
+		return nil, errors.New("downstream error")
+	})
+
+	expect.EqualError(err, "downstream error")
+	expect.False(shared)
+}
+
+func TestFlightGroup_RecoversPanics(t *testing.T) {
+	expect := assert.New(t)
+
+	var group flightGroup
+	result, err, shared := group.do("key", func() (interface{}, error) {
+		panic("boom")
+	})
+
+	expect.Nil(result)
+	expect.EqualError(err, "in-flight call panicked: boom")
+	expect.False(shared)
+
+	// the key is released for the next calls
+	result, err, _ = group.do("key", func() (interface{}, error) {
+		return "result", nil
+	})
+	expect.NoError(err)
+	expect.Equal("result", result)
+}
+
+func TestFlightGroup_SequentialCallsAreNotCollapsed(t *testing.T) {
+	expect := assert.New(t)
+
+	var group flightGroup
+	calls := 0
+	fn := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	first, _, _ := group.do("key", fn)
+	second, _, _ := group.do("key", fn)
+
+	expect.Equal(1, first)
+	expect.Equal(2, second)
+}
+
+func TestIdsKey_OrderIndependent(t *testing.T) {
+	ids := []string{"b", "a", "c"}
+	assert.Equal(t, idsKey([]string{"a", "b", "c"}), idsKey(ids))
+	assert.Equal(t, []string{"b", "a", "c"}, ids, "the ids should not be reordered")
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 
 	health "github.com/Financial-Times/go-fthealth/v1_1"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
 )
 
 const (
@@ -23,6 +25,7 @@ const (
 	ontologyTopicType = "http://www.ft.com/ontology/Topic"
 
 	predicateHasAuthor = "http://www.ft.com/ontology/annotation/hasAuthor"
+	predicateMentions  = "http://www.ft.com/ontology/annotation/mentions"
 )
 
 var (
@@ -76,6 +79,8 @@ type SuggestionApi struct {
 	client               Client
 	systemId             string
 	failureImpact        string
+	// Hedging, when set, hedges the slow suggestion requests.
+	Hedging *Hedging
 }
 
 type AuthorsSuggester struct {
@@ -88,19 +93,29 @@ type OntotextSuggester struct {
 
 type Suggestion struct {
 	Concept
-	Predicate string `json:"predicate,omitempty"`
+	Predicate   string          `json:"predicate,omitempty"`
+	OriginalID  string          `json:"originalId,omitempty"`
+	Unconcorded bool            `json:"unconcorded,omitempty"`
+	Sources     []string        `json:"sources,omitempty"`
+	BroaderOf   []string        `json:"broaderOf,omitempty"`
+	Provenance  string          `json:"provenance,omitempty"`
+	ImpliedBy   []string        `json:"impliedBy,omitempty"`
+	Details     *ConceptDetails `json:"details,omitempty"`
 }
 
 type Concept struct {
-	ID         string `json:"id"`
-	APIURL     string `json:"apiUrl,omitempty"`
-	Type       string `json:"type,omitempty"`
-	PrefLabel  string `json:"prefLabel,omitempty"`
-	IsFTAuthor bool   `json:"isFTAuthor,omitempty"`
+	ID           string `json:"id"`
+	APIURL       string `json:"apiUrl,omitempty"`
+	Type         string `json:"type,omitempty"`
+	PrefLabel    string `json:"prefLabel,omitempty"`
+	IsFTAuthor   bool   `json:"isFTAuthor,omitempty"`
+	IsDeprecated bool   `json:"isDeprecated,omitempty"`
 }
 
 type SuggestionsResponse struct {
 	Suggestions []Suggestion `json:"suggestions"`
+	// Degraded lists how the suggestions were degraded by failing downstream services.
+	Degraded []string `json:"degraded,omitempty"`
 }
 
 func NewAuthorsSuggester(authorsSuggestionApiBaseURL, authorsSuggestionEndpoint string, client Client) *AuthorsSuggester {
@@ -115,6 +130,10 @@ func NewAuthorsSuggester(authorsSuggestionApiBaseURL, authorsSuggestionEndpoint
 	}}
 }
 
+func (suggester *AuthorsSuggester) suggestsFromByline() bool {
+	return true
+}
+
 func NewOntotextSuggester(ontotextSuggestionApiBaseURL, ontotextSuggestionEndpoint string, client Client) *OntotextSuggester {
 	return &OntotextSuggester{SuggestionApi{
 		apiBaseURL:           ontotextSuggestionApiBaseURL,
@@ -128,8 +147,14 @@ func NewOntotextSuggester(ontotextSuggestionApiBaseURL, ontotextSuggestionEndpoi
 }
 
 func (suggester *SuggestionApi) GetSuggestions(payload []byte, tid string) (SuggestionsResponse, error) {
+	if suggester.Hedging != nil {
+		return suggester.hedgedSuggestions(payload, tid)
+	}
+	return suggester.requestSuggestions(context.Background(), suggester.apiBaseURL, payload, tid)
+}
 
-	req, err := http.NewRequest("POST", suggester.apiBaseURL+suggester.suggestionEndpoint, bytes.NewReader(payload))
+func (suggester *SuggestionApi) requestSuggestions(ctx context.Context, baseURL string, payload []byte, tid string) (SuggestionsResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+suggester.suggestionEndpoint, bytes.NewReader(payload))
 	if err != nil {
 		return SuggestionsResponse{}, err
 	}
@@ -152,10 +177,10 @@ func (suggester *SuggestionApi) GetSuggestions(payload []byte, tid string) (Sugg
 
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusNoContent {
-			return SuggestionsResponse{make([]Suggestion, 0)}, NoContentError
+			return SuggestionsResponse{Suggestions: make([]Suggestion, 0)}, NoContentError
 		}
 		if resp.StatusCode == http.StatusBadRequest {
-			return SuggestionsResponse{make([]Suggestion, 0)}, BadRequestError
+			return SuggestionsResponse{Suggestions: make([]Suggestion, 0)}, BadRequestError
 		}
 		return SuggestionsResponse{}, fmt.Errorf("%v returned HTTP %v", suggester.name, resp.StatusCode)
 	}
@@ -219,3 +244,22 @@ func (suggester *SuggestionApi) healthCheck() (string, error) {
 	}
 	return fmt.Sprintf("%v is healthy", suggester.name), nil
 }
+
+// Probe sends a canned article through the suggestion endpoint and validates the shape of the response.
+func (suggester *SuggestionApi) Probe() (string, error) {
+	payload, err := getXmlSuggestionRequestFromJson(healthcheckArticle)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := suggester.GetSuggestions(payload, tidutils.NewTransactionID())
+	if err != nil && !errors.Is(err, NoContentError) {
+		return "", err
+	}
+	for _, suggestion := range resp.Suggestions {
+		if suggestion.ID == "" {
+			return "", fmt.Errorf("%v returned a suggestion without an id for the probe article", suggester.name)
+		}
+	}
+	return fmt.Sprintf("%v returned %v suggestions for the probe article", suggester.name, len(resp.Suggestions)), nil
+}
@@ -446,3 +446,42 @@ func TestOntotext_ErrorFromService(t *testing.T) {
 	expect.NotNil(resp)
 	expect.Len(resp.Suggestions, 0)
 }
+
+func TestOntotextSuggester_ProbeSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == http.MethodPost && req.URL.String() == "ontotextUrl/ontotextEndpoint"
+	})).Return(&http.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte(sampleJSONResponse))), StatusCode: http.StatusOK}, nil)
+
+	suggester := NewOntotextSuggester("ontotextUrl", "/ontotextEndpoint", mockClient)
+	output, err := suggester.Probe()
+
+	expect.NoError(err)
+	expect.Equal("Ontotext Suggestion API returned 2 suggestions for the probe article", output)
+	mockClient.AssertExpectations(t)
+}
+
+func TestOntotextSuggester_ProbeNoContent(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte{})), StatusCode: http.StatusNoContent}, nil)
+
+	suggester := NewOntotextSuggester("ontotextUrl", "/ontotextEndpoint", mockClient)
+	output, err := suggester.Probe()
+
+	expect.NoError(err)
+	expect.Equal("Ontotext Suggestion API returned 0 suggestions for the probe article", output)
+}
+
+func TestOntotextSuggester_ProbeInvalidResponse(t *testing.T) {
+	expect := assert.New(t)
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte(`{"suggestions":[{"prefLabel":"London"}]}`))), StatusCode: http.StatusOK}, nil)
+
+	suggester := NewOntotextSuggester("ontotextUrl", "/ontotextEndpoint", mockClient)
+	output, err := suggester.Probe()
+
+	expect.Empty(output)
+	expect.EqualError(err, "Ontotext Suggestion API returned a suggestion without an id for the probe article")
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/public-suggestions-api/service"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/gorilla/mux"
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+// AdminHandler manages the local blacklist overlay. All its endpoints require the admin API key.
+type AdminHandler struct {
+	overlay *service.BlacklistOverlay
+	apiKey  string
+	log     *logger.UPPLogger
+}
+
+type blacklistEntryRequest struct {
+	UUID      string     `json:"uuid"`
+	Reason    string     `json:"reason"`
+	Author    string     `json:"author"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func NewAdminHandler(overlay *service.BlacklistOverlay, apiKey string, log *logger.UPPLogger) *AdminHandler {
+	return &AdminHandler{
+		overlay: overlay,
+		apiKey:  apiKey,
+		log:     log,
+	}
+}
+
+// Authenticate rejects the requests without the admin API key.
+func (h *AdminHandler) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get(apiKeyHeader)
+		if h.apiKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(h.apiKey)) != 1 {
+			h.log.WithTransactionID(tidutils.GetTransactionIDFromRequest(req)).Warnf("Rejected admin request %v %v with a missing or invalid API key", req.Method, req.URL.Path)
+			writeResponse(resp, http.StatusUnauthorized, []byte(`{"message": "Missing or invalid API key"}`))
+			return
+		}
+		next(resp, req)
+	}
+}
+
+func (h *AdminHandler) HandleListBlacklist(resp http.ResponseWriter, req *http.Request) {
+	//ignoring marshalling errors as neither UnsupportedTypeError nor UnsupportedValueError is possible
+	jsonResponse, _ := json.Marshal(h.overlay.List())
+	writeResponse(resp, http.StatusOK, jsonResponse)
+}
+
+func (h *AdminHandler) HandleAddToBlacklist(resp http.ResponseWriter, req *http.Request) {
+	tid := tidutils.GetTransactionIDFromRequest(req)
+	logEntry := h.log.WithTransactionID(tid)
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logEntry.WithError(err).Error("Error while reading blacklist entry")
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Error while reading blacklist entry"}`))
+		return
+	}
+	var entryReq blacklistEntryRequest
+	if err := json.Unmarshal(body, &entryReq); err != nil {
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Blacklist entry should be a JSON object"}`))
+		return
+	}
+	if !uuidRegex.MatchString(entryReq.UUID) {
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Invalid concept UUID"}`))
+		return
+	}
+	if entryReq.Reason == "" {
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "A reason is required"}`))
+		return
+	}
+	if entryReq.ExpiresAt != nil && !entryReq.ExpiresAt.After(time.Now()) {
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Expiry should be in the future"}`))
+		return
+	}
+
+	// the suggested concepts have lower-case UUIDs
+	entry, err := h.overlay.Add(service.BlacklistEntry{
+		UUID:      strings.ToLower(entryReq.UUID),
+		Reason:    entryReq.Reason,
+		Author:    entryReq.Author,
+		ExpiresAt: entryReq.ExpiresAt,
+	})
+	if err != nil {
+		logEntry.WithError(err).Error("Error while saving blacklist entry")
+		writeResponse(resp, http.StatusInternalServerError, []byte(`{"message": "Error while saving blacklist entry"}`))
+		return
+	}
+	logEntry.Infof("Blacklisted concept %v locally by %v: %v", entry.UUID, entry.Author, entry.Reason)
+
+	//ignoring marshalling errors as neither UnsupportedTypeError nor UnsupportedValueError is possible
+	jsonResponse, _ := json.Marshal(entry)
+	writeResponse(resp, http.StatusCreated, jsonResponse)
+}
+
+func (h *AdminHandler) HandleRemoveFromBlacklist(resp http.ResponseWriter, req *http.Request) {
+	tid := tidutils.GetTransactionIDFromRequest(req)
+	logEntry := h.log.WithTransactionID(tid)
+
+	uuid := strings.ToLower(mux.Vars(req)["uuid"])
+	removed, err := h.overlay.Remove(uuid)
+	if err != nil {
+		logEntry.WithError(err).Error("Error while removing blacklist entry")
+		writeResponse(resp, http.StatusInternalServerError, []byte(`{"message": "Error while removing blacklist entry"}`))
+		return
+	}
+	if !removed {
+		writeResponse(resp, http.StatusNotFound, []byte(`{"message": "Concept is not blacklisted locally"}`))
+		return
+	}
+	logEntry.Infof("Removed concept %v from the local blacklist", uuid)
+	resp.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/public-suggestions-api/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConceptUUID = "6f14ea94-690f-3ed4-98c7-b926683c735a"
+
+func newTestAdminHandler(t *testing.T) (*AdminHandler, *service.BlacklistOverlay) {
+	overlay, err := service.NewBlacklistOverlay("")
+	require.NoError(t, err)
+	return NewAdminHandler(overlay, "secret", logger.NewUPPLogger("test-logger", "panic")), overlay
+}
+
+func TestAdminHandler_AuthenticateRejectsInvalidKeys(t *testing.T) {
+	expect := assert.New(t)
+	handler, _ := newTestAdminHandler(t)
+	authenticated := handler.Authenticate(handler.HandleListBlacklist)
+
+	for _, apiKey := range []string{"", "wrong"} {
+		req := httptest.NewRequest("GET", "/__admin/blacklist", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		authenticated(w, req)
+
+		expect.Equal(http.StatusUnauthorized, w.Code)
+		expect.Equal(`{"message": "Missing or invalid API key"}`, w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/__admin/blacklist", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	w := httptest.NewRecorder()
+	authenticated(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal(`[]`, w.Body.String())
+}
+
+func TestAdminHandler_AddListAndRemove(t *testing.T) {
+	expect := assert.New(t)
+	handler, overlay := newTestAdminHandler(t)
+
+	req := httptest.NewRequest("POST", "/__admin/blacklist", strings.NewReader(`{"uuid":"`+testConceptUUID+`","reason":"legal","author":"editor","expiresAt":"2100-01-01T00:00:00Z"}`))
+	w := httptest.NewRecorder()
+	handler.HandleAddToBlacklist(w, req)
+
+	expect.Equal(http.StatusCreated, w.Code)
+	expect.Contains(w.Body.String(), `"uuid":"`+testConceptUUID+`","reason":"legal","author":"editor"`)
+	expect.Equal([]string{testConceptUUID}, overlay.UUIDs())
+
+	req = httptest.NewRequest("GET", "/__admin/blacklist", nil)
+	w = httptest.NewRecorder()
+	handler.HandleListBlacklist(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Contains(w.Body.String(), `"expiresAt":"2100-01-01T00:00:00Z"`)
+
+	req = httptest.NewRequest("DELETE", "/__admin/blacklist/"+testConceptUUID, nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": testConceptUUID})
+	w = httptest.NewRecorder()
+	handler.HandleRemoveFromBlacklist(w, req)
+
+	expect.Equal(http.StatusNoContent, w.Code)
+	expect.Empty(overlay.UUIDs())
+
+	w = httptest.NewRecorder()
+	handler.HandleRemoveFromBlacklist(w, req)
+
+	expect.Equal(http.StatusNotFound, w.Code)
+	expect.Equal(`{"message": "Concept is not blacklisted locally"}`, w.Body.String())
+}
+
+func TestAdminHandler_AddAndRemoveUpperCaseUUIDs(t *testing.T) {
+	expect := assert.New(t)
+	handler, overlay := newTestAdminHandler(t)
+	upperCaseUUID := strings.ToUpper(testConceptUUID)
+
+	req := httptest.NewRequest("POST", "/__admin/blacklist", strings.NewReader(`{"uuid":"`+upperCaseUUID+`","reason":"legal"}`))
+	w := httptest.NewRecorder()
+	handler.HandleAddToBlacklist(w, req)
+
+	expect.Equal(http.StatusCreated, w.Code)
+	expect.Equal([]string{testConceptUUID}, overlay.UUIDs())
+
+	req = httptest.NewRequest("DELETE", "/__admin/blacklist/"+upperCaseUUID, nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": upperCaseUUID})
+	w = httptest.NewRecorder()
+	handler.HandleRemoveFromBlacklist(w, req)
+
+	expect.Equal(http.StatusNoContent, w.Code)
+	expect.Empty(overlay.UUIDs())
+}
+
+func TestAdminHandler_AddInvalidEntries(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedMessage string
+	}{
+		{"not json", `not json`, `{"message": "Blacklist entry should be a JSON object"}`},
+		{"invalid uuid", `{"uuid":"not-a-uuid","reason":"legal"}`, `{"message": "Invalid concept UUID"}`},
+		{"no reason", `{"uuid":"` + testConceptUUID + `"}`, `{"message": "A reason is required"}`},
+		{"past expiry", `{"uuid":"` + testConceptUUID + `","reason":"legal","expiresAt":"2000-01-01T00:00:00Z"}`, `{"message": "Expiry should be in the future"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, overlay := newTestAdminHandler(t)
+
+			req := httptest.NewRequest("POST", "/__admin/blacklist", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			handler.HandleAddToBlacklist(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, test.expectedMessage, w.Body.String())
+			assert.Empty(t, overlay.UUIDs())
+		})
+	}
+}
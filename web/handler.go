@@ -1,39 +1,200 @@
 package web
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/public-suggestions-api/service"
 	tidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/gorilla/mux"
 )
 
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+const laneHeader = "X-Request-Lane"
+
 type RequestHandler struct {
-	suggester *service.AggregateSuggester
-	log       *logger.UPPLogger
+	suggester            *service.AggregateSuggester
+	contentRetriever     service.ContentRetriever
+	annotationsRetriever service.AnnotationsRetriever
+	log                  *logger.UPPLogger
+	// lane is the lane of the requests without an X-Request-Lane header
+	lane service.Lane
+	// MaxPayloadBytes rejects the bigger payloads with a 413. 0 accepts payloads of any size.
+	MaxPayloadBytes int64
+	// ShedRetryAfter is sent to the clients of the requests shed for lack of capacity in their lane.
+	ShedRetryAfter time.Duration
+}
+
+type diffRequest struct {
+	Annotations []service.Annotation `json:"annotations"`
 }
 
-func NewRequestHandler(s *service.AggregateSuggester, log *logger.UPPLogger) *RequestHandler {
+func NewRequestHandler(s *service.AggregateSuggester, contentRetriever service.ContentRetriever, annotationsRetriever service.AnnotationsRetriever, log *logger.UPPLogger) *RequestHandler {
 	return &RequestHandler{
-		suggester: s,
-		log:       log,
+		suggester:            s,
+		contentRetriever:     contentRetriever,
+		annotationsRetriever: annotationsRetriever,
+		log:                  log,
+		ShedRetryAfter:       time.Second,
+	}
+}
+
+// WithLane returns a handler putting the requests without an X-Request-Lane header in the given lane, e.g. for the endpoints of batch jobs.
+func (h *RequestHandler) WithLane(lane service.Lane) *RequestHandler {
+	laned := *h
+	laned.lane = lane
+	return &laned
+}
+
+// LimitInteractive applies the concurrency limiter to the requests of the interactive lane. When the aggregations run in lanes,
+// the bulk requests are only bounded by the bulk lane, so that bulk traffic can't shed the interactive requests.
+// They are handled whole in their turn, so that the content and annotations they fetch are bounded too.
+func (h *RequestHandler) LimitInteractive(limiter *ConcurrencyLimiter, next http.HandlerFunc) http.HandlerFunc {
+	limited := limiter.Limit(next)
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if h.suggester.Lanes != nil {
+			// invalid lanes are rejected by the handler
+			if lane, err := requestLane(req, h.lane); err == nil && lane == service.LaneBulk {
+				h.handleInLane(resp, req, lane, next)
+				return
+			}
+		}
+		limited(resp, req)
+	}
+}
+
+func (h *RequestHandler) handleInLane(resp http.ResponseWriter, req *http.Request, lane service.Lane, next http.HandlerFunc) {
+	ctx, leave, err := h.suggester.Lanes.Enter(req.Context(), lane)
+	if err != nil {
+		h.log.WithTransactionID(tidutils.GetTransactionIDFromRequest(req)).WithError(err).Warnf("Shed request in the %v lane", lane)
+		writeShedResponse(resp, http.StatusServiceUnavailable, h.ShedRetryAfter)
+		return
 	}
+	defer leave()
+	next(resp, req.WithContext(ctx))
 }
 
 func (h *RequestHandler) HandleSuggestion(resp http.ResponseWriter, req *http.Request) {
 
+	tid := tidutils.GetTransactionIDFromRequest(req)
+
+	body, ok := h.readPayload(resp, req, tid)
+	if !ok {
+		return
+	}
+
+	suggestions, ok := h.getSuggestions(resp, req, body, tid)
+	if !ok {
+		return
+	}
+	writeSuggestions(resp, req, suggestions)
+}
+
+func (h *RequestHandler) HandleSuggestionByUUID(resp http.ResponseWriter, req *http.Request) {
+
+	tid := tidutils.GetTransactionIDFromRequest(req)
+
+	_, body, ok := h.retrieveContent(resp, req, tid)
+	if !ok {
+		return
+	}
+
+	suggestions, ok := h.getSuggestions(resp, req, body, tid)
+	if !ok {
+		return
+	}
+	writeSuggestions(resp, req, suggestions)
+}
+
+func (h *RequestHandler) HandleSuggestionDiff(resp http.ResponseWriter, req *http.Request) {
+
+	tid := tidutils.GetTransactionIDFromRequest(req)
+	logEntry := h.log.WithTransactionID(tid)
+
+	body, ok := h.readPayload(resp, req, tid)
+	if !ok {
+		return
+	}
+
+	var diffReq diffRequest
+	if err := json.Unmarshal(body, &diffReq); err != nil {
+		logEntry.WithError(err).Error("Client error: annotations should be a JSON array of annotations")
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Annotations should be a JSON array of annotations"}`))
+		return
+	}
+
+	suggestions, ok := h.getSuggestions(resp, req, body, tid)
+	if !ok {
+		return
+	}
+	h.writeDiff(resp, suggestions, diffReq.Annotations, tid)
+}
+
+func (h *RequestHandler) HandleSuggestionDiffByUUID(resp http.ResponseWriter, req *http.Request) {
+
 	tid := tidutils.GetTransactionIDFromRequest(req)
 	logEntry := h.log.WithTransactionID(tid)
 
-	body, err := ioutil.ReadAll(req.Body)
+	uuid, body, ok := h.retrieveContent(resp, req, tid)
+	if !ok {
+		return
+	}
+
+	var annotations []service.Annotation
+	var annotationsErr error
+	var wg = sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		annotations, annotationsErr = h.annotationsRetriever.GetAnnotations(uuid, tid)
+	}()
+
+	suggestions, ok := h.getSuggestions(resp, req, body, tid)
+	wg.Wait()
+	if !ok {
+		return
+	}
+
+	if annotationsErr != nil {
+		errMsg := "retrieving existing annotations failed!"
+		logEntry.WithError(annotationsErr).Error(errMsg)
+		writeResponse(resp, http.StatusServiceUnavailable, []byte(fmt.Sprintf(`{"message": "%s"}`, errMsg)))
+		return
+	}
+	h.writeDiff(resp, suggestions, annotations, tid)
+}
+
+func (h *RequestHandler) readPayload(resp http.ResponseWriter, req *http.Request, tid string) ([]byte, bool) {
+	logEntry := h.log.WithTransactionID(tid)
+
+	var reader io.Reader = req.Body
+	if h.MaxPayloadBytes > 0 {
+		// reading a byte past the limit tells the payloads over it apart
+		reader = io.LimitReader(req.Body, h.MaxPayloadBytes+1)
+	}
+	body, err := ioutil.ReadAll(reader)
 	if err != nil {
 		logEntry.WithError(err).Error("Error while reading payload")
 		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Error while reading payload"}`))
-		return
+		return nil, false
+	}
+	if h.MaxPayloadBytes > 0 && int64(len(body)) > h.MaxPayloadBytes {
+		logEntry.Warnf("Client error: payload is larger than %v bytes", h.MaxPayloadBytes)
+		writeResponse(resp, http.StatusRequestEntityTooLarge, []byte(fmt.Sprintf(`{"message": "Payload should not be larger than %v bytes"}`, h.MaxPayloadBytes)))
+		return nil, false
 	}
 
 	logEntry.Debugf("request body: %s", string(body))
@@ -41,26 +202,185 @@ func (h *RequestHandler) HandleSuggestion(resp http.ResponseWriter, req *http.Re
 	if !validPayload {
 		logEntry.WithError(err).Error("Client error: payload should be a non-empty JSON object")
 		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Payload should be a non-empty JSON object"}`))
-		return
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *RequestHandler) retrieveContent(resp http.ResponseWriter, req *http.Request, tid string) (string, []byte, bool) {
+	logEntry := h.log.WithTransactionID(tid)
+
+	uuid := mux.Vars(req)["uuid"]
+	if !uuidRegex.MatchString(uuid) {
+		logEntry.Errorf("Client error: invalid content UUID %s", uuid)
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Invalid content UUID"}`))
+		return uuid, nil, false
+	}
+
+	body, err := h.contentRetriever.GetContent(uuid, tid)
+	if err != nil {
+		if errors.Is(err, service.ContentNotFoundError) {
+			logEntry.WithError(err).Warnf("Content %s not found", uuid)
+			writeResponse(resp, http.StatusNotFound, []byte(`{"message": "Content not found"}`))
+			return uuid, nil, false
+		}
+		errMsg := "retrieving content failed!"
+		logEntry.WithError(err).Error(errMsg)
+		writeResponse(resp, http.StatusServiceUnavailable, []byte(fmt.Sprintf(`{"message": "%s"}`, errMsg)))
+		return uuid, nil, false
+	}
+
+	validPayload, err := validatePayload(body)
+	if !validPayload {
+		errMsg := "retrieved content is not a non-empty JSON object"
+		logEntry.WithError(err).Error(errMsg)
+		writeResponse(resp, http.StatusServiceUnavailable, []byte(fmt.Sprintf(`{"message": "%s"}`, errMsg)))
+		return uuid, nil, false
 	}
+	return uuid, body, true
+}
 
-	suggestions, err := h.suggester.GetSuggestions(body, tid)
+func (h *RequestHandler) getSuggestions(resp http.ResponseWriter, req *http.Request, body []byte, tid string) (service.SuggestionsResponse, bool) {
+	logEntry := h.log.WithTransactionID(tid)
+
+	opts, err := suggestionOptions(req)
+	if err != nil {
+		logEntry.WithError(err).Warn("Invalid suggestion options")
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Invalid broaderPolicy, broaderDepth, include or merge query parameter"}`))
+		return service.SuggestionsResponse{}, false
+	}
+	opts.Lane, err = requestLane(req, h.lane)
+	if err != nil {
+		logEntry.WithError(err).Warn("Invalid request lane")
+		writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Invalid X-Request-Lane header"}`))
+		return service.SuggestionsResponse{}, false
+	}
+	suggestions, err := h.suggester.GetSuggestionsWithContext(req.Context(), body, tid, opts)
+	if errors.Is(err, service.ErrLaneSaturated) || (err != nil && req.Context().Err() != nil) {
+		logEntry.WithError(err).Warnf("Shed request in the %v lane", opts.Lane)
+		writeShedResponse(resp, http.StatusServiceUnavailable, h.ShedRetryAfter)
+		return suggestions, false
+	}
 	if err != nil {
 		errMsg := "aggregating suggestions failed!"
 		logEntry.WithError(err).Error(errMsg)
 		writeResponse(resp, http.StatusServiceUnavailable, []byte(fmt.Sprintf(`{"message": "%s"}`, errMsg)))
-		return
+		return suggestions, false
 	}
 
 	if len(suggestions.Suggestions) == 0 {
 		logEntry.Warn("Suggestions are empty")
 	}
+	return suggestions, true
+}
+
+func (h *RequestHandler) writeDiff(resp http.ResponseWriter, suggestions service.SuggestionsResponse, annotations []service.Annotation, tid string) {
+	logEntry := h.log.WithTransactionID(tid)
+
+	diff, err := h.suggester.DiffSuggestions(suggestions.Suggestions, annotations, tid)
+	if err != nil {
+		errMsg := "comparing suggestions with existing annotations failed!"
+		logEntry.WithError(err).Error(errMsg)
+		writeResponse(resp, http.StatusServiceUnavailable, []byte(fmt.Sprintf(`{"message": "%s"}`, errMsg)))
+		return
+	}
+
+	//ignoring marshalling errors as neither UnsupportedTypeError nor UnsupportedValueError is possible
+	jsonResponse, _ := json.Marshal(diff)
+
+	writeResponse(resp, http.StatusOK, jsonResponse)
+}
+
+func writeSuggestions(resp http.ResponseWriter, req *http.Request, suggestions service.SuggestionsResponse) {
 	//ignoring marshalling errors as neither UnsupportedTypeError nor UnsupportedValueError is possible
 	jsonResponse, _ := json.Marshal(suggestions)
 
+	hash := sha256.Sum256(jsonResponse)
+	etag := `"` + hex.EncodeToString(hash[:]) + `"`
+	resp.Header().Set("ETag", etag)
+	if matchesETag(req.Header.Get("If-None-Match"), etag) {
+		// only the suggestions of the content retrieved by UUID can be not modified, the others fail the precondition
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			resp.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeResponse(resp, http.StatusPreconditionFailed, []byte(`{"message": "The suggestions match the ETag given in If-None-Match"}`))
+		return
+	}
+
 	writeResponse(resp, http.StatusOK, jsonResponse)
 }
 
+// matchesETag tells whether the If-None-Match header lists the ETag. A * doesn't match suggestions, which are computed for each request.
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func suggestionOptions(req *http.Request) (service.SuggestionOptions, error) {
+	opts := service.SuggestionOptions{
+		NoCache: hasNoCacheDirective(req),
+	}
+
+	query := req.URL.Query()
+	if value := query.Get("broaderPolicy"); value != "" {
+		policy, err := service.ParseBroaderPolicy(value)
+		if err != nil {
+			return opts, err
+		}
+		opts.BroaderPolicy = policy
+	}
+	if value := query.Get("broaderDepth"); value != "" {
+		depth, err := service.ParseBroaderDepth(value)
+		if err != nil {
+			return opts, err
+		}
+		opts.BroaderDepth = depth
+	}
+	if value := query.Get("merge"); value != "" {
+		merge, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, err
+		}
+		opts.Merge = merge
+	}
+	for _, value := range strings.Split(query.Get("include"), ",") {
+		switch strings.TrimSpace(value) {
+		case "":
+		case "implied":
+			opts.IncludeImplied = true
+		case "details":
+			opts.IncludeDetails = true
+		default:
+			return opts, fmt.Errorf("unknown include value %q", value)
+		}
+	}
+	return opts, nil
+}
+
+// requestLane returns the lane of the X-Request-Lane header, or the given lane without one.
+func requestLane(req *http.Request, lane service.Lane) (service.Lane, error) {
+	value := req.Header.Get(laneHeader)
+	if value == "" {
+		return lane, nil
+	}
+	return service.ParseLane(strings.ToLower(strings.TrimSpace(value)))
+}
+
+func hasNoCacheDirective(req *http.Request) bool {
+	for _, directive := range strings.Split(req.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return strings.EqualFold(req.Header.Get("Pragma"), "no-cache")
+}
+
 func validatePayload(content []byte) (bool, error) {
 	var payload map[string]interface{}
 	if err := json.Unmarshal(content, &payload); err != nil {
@@ -2,17 +2,21 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/public-suggestions-api/service"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -31,6 +35,12 @@ func (c *mockHttpClient) Do(req *http.Request) (resp *http.Response, err error)
 	return args.Get(0).(*http.Response), args.Error(1)
 }
 
+type stubHttpClient func(req *http.Request) (*http.Response, error)
+
+func (c stubHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return c(req)
+}
+
 type ClosingBuffer struct {
 	*bytes.Buffer
 }
@@ -130,7 +140,7 @@ func TestRequestHandler_HandleSuggestionSuccessfully(t *testing.T) {
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 	service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusOK, w.Code)
@@ -166,7 +176,7 @@ func TestRequestHandler_HandleSuggestionErrorOnRequestRead(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusBadRequest, w.Code)
@@ -203,7 +213,7 @@ func TestRequestHandler_HandleSuggestionEmptyBody(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusBadRequest, w.Code)
@@ -240,7 +250,7 @@ func TestRequestHandler_HandleSuggestionEmptyJsonRequest(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusBadRequest, w.Code)
@@ -279,7 +289,7 @@ func TestRequestHandler_HandleSuggestionErrorOnGetSuggestions(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusOK, w.Code)
@@ -320,7 +330,7 @@ func TestRequestHandler_HandleSuggestionOkWhenNoContentSuggestions(t *testing.T)
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusOK, w.Code)
@@ -359,7 +369,7 @@ func TestRequestHandler_HandleSuggestionOkWhenEmptySuggestions(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusOK, w.Code)
@@ -396,6 +406,11 @@ func TestRequestHandler_HandleSuggestionErrorOnGetConcordance(t *testing.T) {
 		},
 	}}, nil)
 	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{}, errors.New("timeout error"))
+	// the broader concepts are looked up while concording the suggestions
+	mockPublicThings.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`{"things":{}}`)),
+		StatusCode: http.StatusOK,
+	}, nil)
 
 	broaderService := &service.BroaderConceptsProvider{
 		Client: mockPublicThings,
@@ -409,7 +424,7 @@ func TestRequestHandler_HandleSuggestionErrorOnGetConcordance(t *testing.T) {
 	}, nil)
 	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
 
-	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), log)
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), nil, nil, log)
 	handler.HandleSuggestion(w, req)
 
 	expect.Equal(http.StatusServiceUnavailable, w.Code)
@@ -419,3 +434,630 @@ func TestRequestHandler_HandleSuggestionErrorOnGetConcordance(t *testing.T) {
 	mockPublicThings.AssertExpectations(t)
 	mockClient.AssertExpectations(t)
 }
+
+type mockContentRetriever struct {
+	mock.Mock
+}
+
+func (m *mockContentRetriever) GetContent(uuid string, tid string) ([]byte, error) {
+	args := m.Called(uuid, tid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockContentRetriever) Check() v1_1.Check {
+	args := m.Called()
+	return args.Get(0).(v1_1.Check)
+}
+
+func TestRequestHandler_HandleSuggestionByUUIDSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+
+	body := []byte(`{"id":"http://www.ft.com/thing/9d5e441e-0b02-11e8-8eb7-42f857ea9f09","bodyXML":"Test body","title":"Test title"}`)
+	req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	mockClient := new(mockHttpClient)
+	mockSuggester := new(mockSuggesterService)
+	mockPublicThings := new(mockHttpClient)
+	mockConcordance := &service.ConcordanceService{ConcordanceBaseURL: "concordanceBaseURL", ConcordanceEndpoint: "concordanceEndpoint", Client: mockClient}
+	broaderService := &service.BroaderConceptsProvider{
+		Client: mockPublicThings,
+	}
+
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return(body, nil)
+	mockSuggester.On("GetSuggestions", mock.Anything, "tid_test").Return(service.SuggestionsResponse{Suggestions: []service.Suggestion{}}, nil)
+
+	blacklisterMock := new(mockHttpClient)
+	blacklisterMock.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"uuids":[]}`)),
+		StatusCode: http.StatusOK,
+	}, nil)
+	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
+
+	handler := NewRequestHandler(service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester), mockRetriever, nil, log)
+	handler.HandleSuggestionByUUID(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal(`{"suggestions":[]}`, w.Body.String())
+
+	mockRetriever.AssertExpectations(t)
+	mockSuggester.AssertExpectations(t)
+}
+
+func TestRequestHandler_HandleSuggestionByUUIDInvalidUUID(t *testing.T) {
+	expect := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/content/not-a-uuid/suggest", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "not-a-uuid"})
+	w := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	mockRetriever := new(mockContentRetriever)
+
+	handler := NewRequestHandler(nil, mockRetriever, nil, log)
+	handler.HandleSuggestionByUUID(w, req)
+
+	expect.Equal(http.StatusBadRequest, w.Code)
+	expect.Equal(`{"message": "Invalid content UUID"}`, w.Body.String())
+	mockRetriever.AssertExpectations(t) //no calls
+}
+
+func TestRequestHandler_HandleSuggestionByUUIDContentNotFound(t *testing.T) {
+	expect := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return(nil, service.ContentNotFoundError)
+
+	handler := NewRequestHandler(nil, mockRetriever, nil, log)
+	handler.HandleSuggestionByUUID(w, req)
+
+	expect.Equal(http.StatusNotFound, w.Code)
+	expect.Equal(`{"message": "Content not found"}`, w.Body.String())
+	mockRetriever.AssertExpectations(t)
+}
+
+func TestRequestHandler_HandleSuggestionByUUIDContentAPIError(t *testing.T) {
+	expect := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return(nil, errors.New("timeout error"))
+
+	handler := NewRequestHandler(nil, mockRetriever, nil, log)
+	handler.HandleSuggestionByUUID(w, req)
+
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+	expect.Equal(`{"message": "retrieving content failed!"}`, w.Body.String())
+	mockRetriever.AssertExpectations(t)
+}
+
+type mockAnnotationsRetriever struct {
+	mock.Mock
+}
+
+func (m *mockAnnotationsRetriever) GetAnnotations(uuid string, tid string) ([]service.Annotation, error) {
+	args := m.Called(uuid, tid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.Annotation), args.Error(1)
+}
+
+func (m *mockAnnotationsRetriever) Check() v1_1.Check {
+	args := m.Called()
+	return args.Get(0).(v1_1.Check)
+}
+
+func newDiffTestSuggester(t *testing.T, suggestions []service.Suggestion, concepts map[string]service.Concept) *service.AggregateSuggester {
+	log := logger.NewUPPLogger("test-logger", "panic")
+
+	mockSuggester := new(mockSuggesterService)
+	mockSuggester.On("GetSuggestions", mock.Anything, "tid_test").Return(service.SuggestionsResponse{Suggestions: suggestions}, nil)
+	mockSuggester.On("FilterSuggestions", mock.Anything).Return(suggestions)
+
+	concordanceBody, err := json.Marshal(service.ConcordanceResponse{Concepts: concepts})
+	require.NoError(t, err)
+	mockConcordance := &service.ConcordanceService{ConcordanceBaseURL: "concordanceBaseURL", ConcordanceEndpoint: "concordanceEndpoint", Client: stubHttpClient(func(*http.Request) (*http.Response, error) {
+		return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(concordanceBody)), StatusCode: http.StatusOK}, nil
+	})}
+
+	broaderService := &service.BroaderConceptsProvider{
+		Client: stubHttpClient(func(*http.Request) (*http.Response, error) {
+			return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"things":{}}`)), StatusCode: http.StatusOK}, nil
+		}),
+	}
+
+	blacklisterMock := new(mockHttpClient)
+	blacklisterMock.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+		Body:       ioutil.NopCloser(strings.NewReader(`{"uuids":[]}`)),
+		StatusCode: http.StatusOK,
+	}, nil)
+	blacklister := service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", blacklisterMock)
+
+	return service.NewAggregateSuggester(log, mockConcordance, broaderService, blacklister, mockSuggester)
+}
+
+func TestRequestHandler_HandleSuggestionDiffSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+
+	london := service.Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: "http://www.ft.com/ontology/Location"}
+	apple := service.Concept{ID: "http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55", PrefLabel: "Apple", Type: "http://www.ft.com/ontology/organisation/Organisation"}
+	suggester := newDiffTestSuggester(t, []service.Suggestion{{Concept: london}, {Concept: apple}}, map[string]service.Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+		"9332270e-f959-3f55-9153-d30acd0d0a55": apple,
+	})
+
+	body := []byte(`{"bodyXML":"Test body","annotations":[{"id":"http://api.ft.com/things/f758ef56-c40a-3162-91aa-3e8a3aabc495","predicate":"http://www.ft.com/ontology/annotation/about"}]}`)
+	req := httptest.NewRequest("POST", "/content/suggest/diff", bytes.NewReader(body))
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	handler := NewRequestHandler(suggester, nil, nil, logger.NewUPPLogger("test-logger", "panic"))
+	handler.HandleSuggestionDiff(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal(`{"added":[{"id":"http://www.ft.com/thing/9332270e-f959-3f55-9153-d30acd0d0a55","type":"http://www.ft.com/ontology/organisation/Organisation","prefLabel":"Apple"}],`+
+		`"alreadyPresent":[{"id":"http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495","type":"http://www.ft.com/ontology/Location","prefLabel":"London"}],`+
+		`"notSuggested":[]}`, w.Body.String())
+}
+
+func TestRequestHandler_HandleSuggestionDiffInvalidAnnotations(t *testing.T) {
+	expect := assert.New(t)
+
+	body := []byte(`{"bodyXML":"Test body","annotations":"not-an-array"}`)
+	req := httptest.NewRequest("POST", "/content/suggest/diff", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler := NewRequestHandler(nil, nil, nil, logger.NewUPPLogger("test-logger", "panic"))
+	handler.HandleSuggestionDiff(w, req)
+
+	expect.Equal(http.StatusBadRequest, w.Code)
+	expect.Equal(`{"message": "Annotations should be a JSON array of annotations"}`, w.Body.String())
+}
+
+func TestRequestHandler_HandleSuggestionDiffByUUIDSuccessfully(t *testing.T) {
+	expect := assert.New(t)
+
+	london := service.Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: "http://www.ft.com/ontology/Location"}
+	brexit := service.Concept{ID: "http://www.ft.com/thing/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990", PrefLabel: "Brexit", Type: "http://www.ft.com/ontology/Topic"}
+	suggester := newDiffTestSuggester(t, []service.Suggestion{{Concept: london}}, map[string]service.Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+		"7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990": brexit,
+	})
+
+	req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest/diff", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return([]byte(`{"bodyXML":"Test body"}`), nil)
+	mockAnnotations := new(mockAnnotationsRetriever)
+	mockAnnotations.On("GetAnnotations", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return([]service.Annotation{
+		{ID: "http://api.ft.com/things/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990", Predicate: "http://www.ft.com/ontology/annotation/about"},
+	}, nil)
+
+	handler := NewRequestHandler(suggester, mockRetriever, mockAnnotations, logger.NewUPPLogger("test-logger", "panic"))
+	handler.HandleSuggestionDiffByUUID(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal(`{"added":[{"id":"http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495","type":"http://www.ft.com/ontology/Location","prefLabel":"London"}],`+
+		`"alreadyPresent":[],`+
+		`"notSuggested":[{"id":"http://www.ft.com/thing/7e78cb61-c6f6-11e8-8ddc-6c96cfdf3990","type":"http://www.ft.com/ontology/Topic","prefLabel":"Brexit","predicate":"http://www.ft.com/ontology/annotation/about"}]}`, w.Body.String())
+	mockRetriever.AssertExpectations(t)
+	mockAnnotations.AssertExpectations(t)
+}
+
+func TestRequestHandler_HandleSuggestionDiffByUUIDAnnotationsError(t *testing.T) {
+	expect := assert.New(t)
+
+	suggester := newDiffTestSuggester(t, []service.Suggestion{}, map[string]service.Concept{})
+
+	req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest/diff", nil)
+	req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return([]byte(`{"bodyXML":"Test body"}`), nil)
+	mockAnnotations := new(mockAnnotationsRetriever)
+	mockAnnotations.On("GetAnnotations", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return(nil, errors.New("timeout error"))
+
+	handler := NewRequestHandler(suggester, mockRetriever, mockAnnotations, logger.NewUPPLogger("test-logger", "panic"))
+	handler.HandleSuggestionDiffByUUID(w, req)
+
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+	expect.Equal(`{"message": "retrieving existing annotations failed!"}`, w.Body.String())
+}
+
+func TestRequestHandler_HandleSuggestionETag(t *testing.T) {
+	expect := assert.New(t)
+
+	london := service.Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: "http://www.ft.com/ontology/Location"}
+	suggester := newDiffTestSuggester(t, []service.Suggestion{{Concept: london}}, map[string]service.Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+	})
+	handler := NewRequestHandler(suggester, nil, nil, logger.NewUPPLogger("test-logger", "panic"))
+
+	body := []byte(`{"bodyXML":"Test body"}`)
+	req := httptest.NewRequest("POST", "/content/suggest", bytes.NewReader(body))
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+	handler.HandleSuggestion(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	expect.NotEmpty(etag)
+
+	// a POST can't be not modified
+	req = httptest.NewRequest("POST", "/content/suggest", bytes.NewReader(body))
+	req.Header.Add("X-Request-Id", "tid_test")
+	req.Header.Add("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.HandleSuggestion(w, req)
+
+	expect.Equal(http.StatusPreconditionFailed, w.Code)
+	expect.Equal(etag, w.Header().Get("ETag"))
+	expect.Equal(`{"message": "The suggestions match the ETag given in If-None-Match"}`, w.Body.String())
+
+	req = httptest.NewRequest("POST", "/content/suggest", bytes.NewReader(body))
+	req.Header.Add("X-Request-Id", "tid_test")
+	req.Header.Add("If-None-Match", `"other-etag"`)
+	w = httptest.NewRecorder()
+	handler.HandleSuggestion(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+}
+
+func TestRequestHandler_HandleSuggestionByUUIDETag(t *testing.T) {
+	expect := assert.New(t)
+
+	london := service.Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: "http://www.ft.com/ontology/Location"}
+	suggester := newDiffTestSuggester(t, []service.Suggestion{{Concept: london}}, map[string]service.Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+	})
+	mockRetriever := new(mockContentRetriever)
+	mockRetriever.On("GetContent", "9d5e441e-0b02-11e8-8eb7-42f857ea9f09", "tid_test").Return([]byte(`{"bodyXML":"Test body"}`), nil)
+	handler := NewRequestHandler(suggester, mockRetriever, nil, logger.NewUPPLogger("test-logger", "panic"))
+	newRequest := func(ifNoneMatch string) *http.Request {
+		req := httptest.NewRequest("GET", "/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest", nil)
+		req = mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+		req.Header.Add("X-Request-Id", "tid_test")
+		if ifNoneMatch != "" {
+			req.Header.Add("If-None-Match", ifNoneMatch)
+		}
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleSuggestionByUUID(w, newRequest(""))
+	expect.Equal(http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	expect.NotEmpty(etag)
+
+	w = httptest.NewRecorder()
+	handler.HandleSuggestionByUUID(w, newRequest(etag))
+	expect.Equal(http.StatusNotModified, w.Code)
+	expect.Equal(etag, w.Header().Get("ETag"))
+	expect.Empty(w.Body.String())
+
+	// the suggestions computed anew aren't taken for any current ones
+	w = httptest.NewRecorder()
+	handler.HandleSuggestionByUUID(w, newRequest("*"))
+	expect.Equal(http.StatusOK, w.Code)
+}
+
+func TestRequestHandler_HandleSuggestionNoCacheBypassesCache(t *testing.T) {
+	expect := assert.New(t)
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	mockSuggester := new(mockSuggesterService)
+	mockSuggester.On("GetSuggestions", mock.Anything, "tid_test").Return(service.SuggestionsResponse{Suggestions: []service.Suggestion{}}, nil).Twice()
+
+	suggester := service.NewAggregateSuggester(log, &service.ConcordanceService{}, &service.BroaderConceptsProvider{},
+		service.NewConceptBlacklister("blacklisterUrl", "blacklisterEndpoint", stubHttpClient(func(*http.Request) (*http.Response, error) {
+			return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"uuids":[]}`)), StatusCode: http.StatusOK}, nil
+		})), mockSuggester)
+	suggester.Cache = service.NewSuggestionsCache(10, time.Minute)
+	handler := NewRequestHandler(suggester, nil, nil, log)
+
+	for _, cacheControl := range []string{"", "no-cache"} {
+		req := httptest.NewRequest("POST", "/content/suggest", strings.NewReader(`{"bodyXML":"Test body"}`))
+		req.Header.Add("X-Request-Id", "tid_test")
+		req.Header.Add("Cache-Control", cacheControl)
+		w := httptest.NewRecorder()
+		handler.HandleSuggestion(w, req)
+		expect.Equal(http.StatusOK, w.Code)
+	}
+
+	mockSuggester.AssertExpectations(t)
+}
+
+func TestMatchesETag(t *testing.T) {
+	expect := assert.New(t)
+
+	expect.True(matchesETag(`"abc"`, `"abc"`))
+	expect.True(matchesETag(`"xyz", W/"abc"`, `"abc"`))
+	expect.False(matchesETag(`*`, `"abc"`))
+	expect.False(matchesETag(``, `"abc"`))
+	expect.False(matchesETag(`"xyz"`, `"abc"`))
+}
+
+func TestRequestHandler_HandleSuggestionAnnotatesBroaderConcepts(t *testing.T) {
+	expect := assert.New(t)
+
+	france := service.Concept{ID: "http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997", PrefLabel: "France", Type: "http://www.ft.com/ontology/Location"}
+	europe := service.Concept{ID: "http://www.ft.com/thing/a1f96d3d-dd01-11e8-b32e-6c96cfdf3997", PrefLabel: "Europe", Type: "http://www.ft.com/ontology/Location"}
+	suggester := newDiffTestSuggester(t, []service.Suggestion{{Concept: europe}, {Concept: france}}, map[string]service.Concept{
+		"a1a2f869-dd01-11e8-abd7-6c96cfdf3997": france,
+		"a1f96d3d-dd01-11e8-b32e-6c96cfdf3997": europe,
+	})
+	var relationships []string
+	suggester.BroaderProvider.Client = stubHttpClient(func(req *http.Request) (*http.Response, error) {
+		relationships = req.URL.Query()["showRelationship"]
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"things":{"a1a2f869-dd01-11e8-abd7-6c96cfdf3997":{"broaderConcepts":[{"id":"` + europe.ID + `"}]}}}`)), StatusCode: http.StatusOK}, nil
+	})
+
+	req := httptest.NewRequest("POST", "/content/suggest?broaderPolicy=demote&broaderDepth=direct", strings.NewReader(`{"bodyXML":"Test body"}`))
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+	NewRequestHandler(suggester, nil, nil, logger.NewUPPLogger("test-logger", "panic")).HandleSuggestion(w, req)
+
+	expect.Equal(http.StatusOK, w.Code)
+	expect.JSONEq(`{"suggestions":[
+		{"id":"http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997","type":"http://www.ft.com/ontology/Location","prefLabel":"France"},
+		{"id":"http://www.ft.com/thing/a1f96d3d-dd01-11e8-b32e-6c96cfdf3997","type":"http://www.ft.com/ontology/Location","prefLabel":"Europe","broaderOf":["http://www.ft.com/thing/a1a2f869-dd01-11e8-abd7-6c96cfdf3997"]}
+	]}`, w.Body.String())
+	expect.Equal([]string{"broader"}, relationships)
+}
+
+func TestSuggestionOptions_Merge(t *testing.T) {
+	expect := assert.New(t)
+
+	for query, merge := range map[string]bool{"": false, "merge=false": false, "merge=true": true} {
+		opts, err := suggestionOptions(httptest.NewRequest("POST", "/content/suggest?"+query, nil))
+		expect.NoError(err, query)
+		expect.Equal(merge, opts.Merge, query)
+	}
+}
+
+func TestRequestHandler_HandleSuggestionInvalidBroaderPolicy(t *testing.T) {
+	expect := assert.New(t)
+
+	for _, query := range []string{"broaderPolicy=hide", "broaderDepth=2", "include=implied,everything", "merge=maybe"} {
+		req := httptest.NewRequest("POST", "/content/suggest?"+query, strings.NewReader(`{"bodyXML":"Test body"}`))
+		req.Header.Add("X-Request-Id", "tid_test")
+		w := httptest.NewRecorder()
+		NewRequestHandler(newDiffTestSuggester(t, nil, nil), nil, nil, logger.NewUPPLogger("test-logger", "panic")).HandleSuggestion(w, req)
+
+		expect.Equal(http.StatusBadRequest, w.Code, query)
+		expect.Equal(`{"message": "Invalid broaderPolicy, broaderDepth, include or merge query parameter"}`, w.Body.String(), query)
+	}
+}
+
+func TestRequestLane(t *testing.T) {
+	expect := assert.New(t)
+
+	req := httptest.NewRequest("POST", "/content/suggest", nil)
+	lane, err := requestLane(req, service.LaneBulk)
+	expect.NoError(err)
+	expect.Equal(service.LaneBulk, lane)
+
+	req.Header.Set("X-Request-Lane", "Interactive")
+	lane, err = requestLane(req, service.LaneBulk)
+	expect.NoError(err)
+	expect.Equal(service.LaneInteractive, lane)
+
+	req.Header.Set("X-Request-Lane", "batch")
+	_, err = requestLane(req, "")
+	expect.Error(err)
+}
+
+func TestRequestHandler_HandleSuggestionInvalidLane(t *testing.T) {
+	expect := assert.New(t)
+
+	req := httptest.NewRequest("POST", "/content/suggest", strings.NewReader(`{"bodyXML":"Test body"}`))
+	req.Header.Add("X-Request-Id", "tid_test")
+	req.Header.Add("X-Request-Lane", "batch")
+	w := httptest.NewRecorder()
+	handler := NewRequestHandler(newDiffTestSuggester(t, nil, nil), nil, nil, logger.NewUPPLogger("test-logger", "panic"))
+	handler.WithLane(service.LaneBulk).HandleSuggestion(w, req)
+
+	expect.Equal(http.StatusBadRequest, w.Code)
+	expect.Equal(`{"message": "Invalid X-Request-Lane header"}`, w.Body.String())
+	expect.Empty(handler.lane)
+}
+
+func TestRequestHandler_HandleSuggestionPayloadTooLarge(t *testing.T) {
+	expect := assert.New(t)
+
+	london := service.Concept{ID: "http://www.ft.com/thing/f758ef56-c40a-3162-91aa-3e8a3aabc495", PrefLabel: "London", Type: "http://www.ft.com/ontology/Location"}
+	handler := NewRequestHandler(newDiffTestSuggester(t, []service.Suggestion{{Concept: london}}, map[string]service.Concept{
+		"f758ef56-c40a-3162-91aa-3e8a3aabc495": london,
+	}), nil, nil, logger.NewUPPLogger("test-logger", "panic"))
+	payload := `{"bodyXML":"London"}`
+	handler.MaxPayloadBytes = int64(len(payload))
+
+	req := httptest.NewRequest("POST", "/content/suggest", strings.NewReader(payload))
+	req.Header.Add("X-Request-Id", "tid_test")
+	w := httptest.NewRecorder()
+	handler.HandleSuggestion(w, req)
+	expect.Equal(http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("POST", "/content/suggest", strings.NewReader(`{"bodyXML":"London "}`))
+	req.Header.Add("X-Request-Id", "tid_test")
+	w = httptest.NewRecorder()
+	handler.HandleSuggestion(w, req)
+	expect.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	expect.Equal(`{"message": "Payload should not be larger than 20 bytes"}`, w.Body.String())
+}
+
+// blockingSuggester holds the suggestions of the payloads containing the given text until released.
+type blockingSuggester struct {
+	service.Suggester
+	text    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSuggester) GetSuggestions(payload []byte, tid string) (service.SuggestionsResponse, error) {
+	if bytes.Contains(payload, []byte(s.text)) {
+		s.started <- struct{}{}
+		<-s.release
+	}
+	return s.Suggester.GetSuggestions(payload, tid)
+}
+
+func newLaneTestRequest(path string, body string) *http.Request {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Add("X-Request-Id", "tid_test")
+	return req
+}
+
+func TestRequestHandler_LimitInteractiveLeavesBulkRequestsToTheirLane(t *testing.T) {
+	expect := assert.New(t)
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	suggester := newDiffTestSuggester(t, nil, nil)
+	blocking := &blockingSuggester{Suggester: suggester.Suggesters[0], text: "Bulk", started: make(chan struct{}), release: make(chan struct{})}
+	suggester.Suggesters[0] = blocking
+	suggester.Lanes = service.NewLanes(2, 1)
+	limiter := NewConcurrencyLimiter(1, log)
+	handler := NewRequestHandler(suggester, nil, nil, log)
+	bulkHandler := handler.WithLane(service.LaneBulk)
+
+	// the bulk lane is saturated
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		bulkHandler.LimitInteractive(limiter, bulkHandler.HandleSuggestion)(w, newLaneTestRequest("/bulk/content/suggest", `{"bodyXML":"Bulk body"}`))
+		done <- w.Code
+	}()
+	<-blocking.started
+	expect.Equal(0, limiter.InFlight())
+
+	w := httptest.NewRecorder()
+	handler.LimitInteractive(limiter, handler.HandleSuggestion)(w, newLaneTestRequest("/content/suggest", `{"bodyXML":"Interactive body"}`))
+	expect.Equal(http.StatusOK, w.Code)
+
+	close(blocking.release)
+	expect.Equal(http.StatusOK, <-done)
+}
+
+// blockingContentRetriever holds the first content fetched until released, counting the fetches.
+type blockingContentRetriever struct {
+	mockContentRetriever
+	fetches int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingContentRetriever) GetContent(uuid string, tid string) ([]byte, error) {
+	if atomic.AddInt32(&r.fetches, 1) == 1 {
+		r.started <- struct{}{}
+		<-r.release
+	}
+	return []byte(`{"bodyXML":"Bulk body"}`), nil
+}
+
+func TestRequestHandler_LimitInteractiveBoundsTheContentFetchesOfBulkRequests(t *testing.T) {
+	expect := assert.New(t)
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	suggester := newDiffTestSuggester(t, nil, nil)
+	suggester.Lanes = service.NewLanes(2, 1)
+	suggester.Lanes.BulkMaxWait = 10 * time.Millisecond
+	retriever := &blockingContentRetriever{started: make(chan struct{}), release: make(chan struct{})}
+	handler := NewRequestHandler(suggester, retriever, nil, log).WithLane(service.LaneBulk)
+	limited := handler.LimitInteractive(NewConcurrencyLimiter(1, log), handler.HandleSuggestionByUUID)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/bulk/content/9d5e441e-0b02-11e8-8eb7-42f857ea9f09/suggest", nil)
+		req.Header.Add("X-Request-Id", "tid_test")
+		return mux.SetURLVars(req, map[string]string{"uuid": "9d5e441e-0b02-11e8-8eb7-42f857ea9f09"})
+	}
+
+	// the content fetch holds the bulk lane
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		limited(w, newRequest())
+		done <- w.Code
+	}()
+	<-retriever.started
+
+	w := httptest.NewRecorder()
+	limited(w, newRequest())
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+	expect.Equal(int32(1), atomic.LoadInt32(&retriever.fetches))
+
+	close(retriever.release)
+	expect.Equal(http.StatusOK, <-done)
+}
+
+func TestRequestHandler_LimitInteractiveShedsBulkRequestsCancelledWhileWaiting(t *testing.T) {
+	expect := assert.New(t)
+
+	log := logger.NewUPPLogger("test-logger", "panic")
+	suggester := newDiffTestSuggester(t, nil, nil)
+	suggester.Lanes = service.NewLanes(1, 1)
+	// the bulk lane is saturated, and the requests wait for it until cancelled
+	_, leave, err := suggester.Lanes.Enter(context.Background(), service.LaneBulk)
+	require.NoError(t, err)
+	defer leave()
+	handler := NewRequestHandler(suggester, nil, nil, log).WithLane(service.LaneBulk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	w := httptest.NewRecorder()
+	handler.LimitInteractive(NewConcurrencyLimiter(1, log), handler.HandleSuggestion)(w,
+		newLaneTestRequest("/bulk/content/suggest", `{"bodyXML":"Bulk body"}`).WithContext(ctx))
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func TestRequestHandler_HandleSuggestionShedsWhenLaneIsSaturated(t *testing.T) {
+	expect := assert.New(t)
+
+	suggester := newDiffTestSuggester(t, nil, nil)
+	blocking := &blockingSuggester{Suggester: suggester.Suggesters[0], text: "Held", started: make(chan struct{}), release: make(chan struct{})}
+	suggester.Suggesters[0] = blocking
+	suggester.Lanes = service.NewLanes(2, 1)
+	suggester.Lanes.BulkMaxWait = 10 * time.Millisecond
+	handler := NewRequestHandler(suggester, nil, nil, logger.NewUPPLogger("test-logger", "panic")).WithLane(service.LaneBulk)
+	handler.ShedRetryAfter = 3 * time.Second
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.HandleSuggestion(w, newLaneTestRequest("/bulk/content/suggest", `{"bodyXML":"Held body"}`))
+		done <- w.Code
+	}()
+	<-blocking.started
+
+	w := httptest.NewRecorder()
+	handler.HandleSuggestion(w, newLaneTestRequest("/bulk/content/suggest", `{"bodyXML":"Other body"}`))
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+	expect.Equal("3", w.Header().Get("Retry-After"))
+	expect.Equal(`{"message": "Too many requests in progress, please retry later"}`, w.Body.String())
+
+	close(blocking.release)
+	expect.Equal(http.StatusOK, <-done)
+}
@@ -1,6 +1,8 @@
 package web
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
@@ -12,6 +14,8 @@ const HealthPath = "/__health"
 type HealthService struct {
 	fthealth.TimedHealthCheck
 	gtgChecks []gtg.StatusChecker
+	// shallowChecks are the checks the good-to-go is based on, even when the health reports deep checks
+	shallowChecks []fthealth.Check
 }
 
 func NewHealthService(appSystemCode string, appName string, appDescription string, checks ...fthealth.Check) *HealthService {
@@ -27,14 +31,90 @@ func NewHealthService(appSystemCode string, appName string, appDescription strin
 		},
 		gtgChecks: []gtg.StatusChecker{
 			func() gtg.Status {
-				// always return as gtg, since we don't want to block overall suggestions if one of the downstream services are not working
+				// non-critical downstream services only degrade the health, since we don't want to block overall suggestions if one of them is not working
 				return gtg.Status{GoodToGo: true}
 			},
 		},
+		shallowChecks: checks,
 	}
 
 }
 
+// UseDeepChecks reports the given checks in the health instead of the checks with the same IDs, e.g. deep checks probing the real downstream endpoints.
+// The good-to-go stays based on the original checks.
+func (service *HealthService) UseDeepChecks(deepChecks ...fthealth.Check) {
+	byID := map[string]fthealth.Check{}
+	for _, check := range deepChecks {
+		byID[check.ID] = check
+	}
+	checks := make([]fthealth.Check, 0, len(service.shallowChecks))
+	for _, check := range service.shallowChecks {
+		if deepCheck, ok := byID[check.ID]; ok {
+			check = deepCheck
+		}
+		checks = append(checks, check)
+	}
+	service.Checks = checks
+}
+
+// SetCriticalChecks makes the good-to-go fail when any of the checks with the given IDs has been failing for at least the unhealthy window.
+func (service *HealthService) SetCriticalChecks(unhealthyWindow time.Duration, ids ...string) error {
+	checks := map[string]fthealth.Check{}
+	for _, check := range service.shallowChecks {
+		checks[check.ID] = check
+	}
+
+	var critical []gtg.StatusChecker
+	for _, id := range ids {
+		check, ok := checks[id]
+		if !ok {
+			return fmt.Errorf("no health check found with ID %v", id)
+		}
+		critical = append(critical, newSustainedFailureCheck(check, unhealthyWindow, time.Now).status)
+	}
+	service.gtgChecks = append(service.gtgChecks, critical...)
+	return nil
+}
+
 func (service *HealthService) GTG() gtg.Status {
 	return gtg.FailFastParallelCheck(service.gtgChecks)()
 }
+
+// sustainedFailureCheck reports a critical check as not good to go only once it has been failing for the whole unhealthy window,
+// so that a single failed call does not take the service out of rotation.
+type sustainedFailureCheck struct {
+	check           fthealth.Check
+	unhealthyWindow time.Duration
+	now             func() time.Time
+
+	mutex          sync.Mutex
+	unhealthySince time.Time
+}
+
+func newSustainedFailureCheck(check fthealth.Check, unhealthyWindow time.Duration, now func() time.Time) *sustainedFailureCheck {
+	return &sustainedFailureCheck{
+		check:           check,
+		unhealthyWindow: unhealthyWindow,
+		now:             now,
+	}
+}
+
+func (c *sustainedFailureCheck) status() gtg.Status {
+	_, err := c.check.Checker()
+	now := c.now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err == nil {
+		c.unhealthySince = time.Time{}
+		return gtg.Status{GoodToGo: true}
+	}
+	if c.unhealthySince.IsZero() {
+		c.unhealthySince = now
+	}
+	if now.Sub(c.unhealthySince) >= c.unhealthyWindow {
+		return gtg.Status{GoodToGo: false, Message: fmt.Sprintf("%v has been failing since %v: %v", c.check.Name, c.unhealthySince.Format(time.RFC3339), err)}
+	}
+	return gtg.Status{GoodToGo: true}
+}
@@ -8,6 +8,7 @@ import (
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewHealthServiceNoChecks(t *testing.T) {
@@ -59,3 +60,94 @@ func TestHealthService_GTGSuccessfullyWhenHealthError(t *testing.T) {
 	expect.Equal("", status.Message)
 	expect.True(status.GoodToGo)
 }
+
+func TestHealthService_SetCriticalChecksUnknownCheck(t *testing.T) {
+	expect := assert.New(t)
+
+	healthService := NewHealthService("", "", "", fthealth.Check{ID: "test-check"})
+	err := healthService.SetCriticalChecks(time.Minute, "unknown-check")
+
+	expect.EqualError(err, "no health check found with ID unknown-check")
+}
+
+func TestHealthService_GTGSuccessfullyWhenCriticalCheckFailsWithinWindow(t *testing.T) {
+	expect := assert.New(t)
+
+	check := fthealth.Check{ID: "critical-check", Name: "critical-check", Checker: func() (string, error) {
+		return "", errors.New("everything-is-error")
+	}}
+
+	healthService := NewHealthService("", "", "", check)
+	expect.NoError(healthService.SetCriticalChecks(time.Minute, "critical-check"))
+
+	status := healthService.GTG()
+	expect.True(status.GoodToGo)
+}
+
+func TestHealthService_GTGFailsWhenCriticalCheckFailsForWindow(t *testing.T) {
+	expect := assert.New(t)
+
+	check := fthealth.Check{ID: "critical-check", Name: "critical-check", Checker: func() (string, error) {
+		return "", errors.New("everything-is-error")
+	}}
+
+	healthService := NewHealthService("", "", "", check)
+	expect.NoError(healthService.SetCriticalChecks(0, "critical-check"))
+
+	status := healthService.GTG()
+	expect.False(status.GoodToGo)
+	expect.Contains(status.Message, "critical-check has been failing since")
+	expect.Contains(status.Message, "everything-is-error")
+}
+
+func TestSustainedFailureCheck_Status(t *testing.T) {
+	expect := assert.New(t)
+
+	var checkErr error
+	check := fthealth.Check{Name: "critical-check", Checker: func() (string, error) {
+		return "", checkErr
+	}}
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	sustained := newSustainedFailureCheck(check, 2*time.Minute, func() time.Time { return now })
+
+	expect.True(sustained.status().GoodToGo)
+
+	checkErr = errors.New("everything-is-error")
+	expect.True(sustained.status().GoodToGo, "first failure is within the window")
+
+	now = now.Add(time.Minute)
+	expect.True(sustained.status().GoodToGo, "still within the window")
+
+	now = now.Add(time.Minute)
+	status := sustained.status()
+	expect.False(status.GoodToGo, "failing for the whole window")
+	expect.Equal("critical-check has been failing since 2020-01-01T10:00:00Z: everything-is-error", status.Message)
+
+	checkErr = nil
+	expect.True(sustained.status().GoodToGo, "recovered")
+
+	checkErr = errors.New("everything-is-error")
+	expect.True(sustained.status().GoodToGo, "window restarts after recovery")
+}
+
+func TestHealthService_GTGIgnoresDeepChecks(t *testing.T) {
+	expect := assert.New(t)
+
+	shallow := fthealth.Check{ID: "critical-check", Name: "critical-check", Checker: func() (string, error) {
+		return "gtg", nil
+	}}
+	deep := fthealth.Check{ID: "critical-check", Name: "critical-check", Checker: func() (string, error) {
+		return "", errors.New("probe failed")
+	}}
+	other := fthealth.Check{ID: "other-check", Name: "other-check", Checker: shallow.Checker}
+
+	healthService := NewHealthService("", "", "", shallow, other)
+	healthService.UseDeepChecks(deep)
+	expect.NoError(healthService.SetCriticalChecks(0, "critical-check"))
+
+	require.Len(t, healthService.Checks, 2)
+	_, err := healthService.Checks[0].Checker()
+	expect.EqualError(err, "probe failed")
+	expect.Equal("other-check", healthService.Checks[1].ID)
+	expect.True(healthService.GTG().GoodToGo)
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/rcrowley/go-metrics"
+)
+
+// adaptiveLatencyWeight is the weight of each latency in the long term average the adaptive limit compares the latencies to.
+const adaptiveLatencyWeight = 0.01
+
+// ConcurrencyLimiter bounds the number of requests handled at once. The requests over the limit wait in a queue for a while,
+// and are shed when the queue is full, with a 429, or when they waited too long, with a 503.
+type ConcurrencyLimiter struct {
+	// QueueSize is the number of requests waiting for their turn. 0 sheds all the requests over the limit.
+	QueueSize int
+	// QueueTimeout is how long a request waits for its turn.
+	QueueTimeout time.Duration
+	// RetryAfter is sent to the clients of the shed requests.
+	RetryAfter time.Duration
+	// Adaptive lowers the limit, down to MinLimit, while the latencies rise above their long term average, and raises it back to the configured limit as they recover.
+	Adaptive bool
+	MinLimit int
+
+	maxLimit int
+	log      *logger.UPPLogger
+	shed     metrics.Counter
+	queued   metrics.Timer
+	current  metrics.Gauge
+
+	mutex       sync.Mutex
+	limit       float64
+	inFlight    int
+	queue       []chan struct{}
+	avgLatency  float64
+	latencySeen bool
+}
+
+// NewConcurrencyLimiter builds a limiter of the given number of requests in flight.
+func NewConcurrencyLimiter(limit int, log *logger.UPPLogger) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		RetryAfter: time.Second,
+		MinLimit:   1,
+		maxLimit:   limit,
+		limit:      float64(limit),
+		log:        log,
+		shed:       metrics.GetOrRegisterCounter("concurrency.shed", metrics.DefaultRegistry),
+		queued:     metrics.GetOrRegisterTimer("concurrency.queued", metrics.DefaultRegistry),
+		current:    metrics.GetOrRegisterGauge("concurrency.limit", metrics.DefaultRegistry),
+	}
+	l.current.Update(int64(limit))
+	return l
+}
+
+// Limit handles the request when it is its turn, or sheds it.
+func (l *ConcurrencyLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		status := l.acquire(req)
+		if status != http.StatusOK {
+			l.shed.Inc(1)
+			l.log.WithTransactionID(tidutils.GetTransactionIDFromRequest(req)).Warnf("Shed request %v %v with %v requests in flight", req.Method, req.URL.Path, l.InFlight())
+			writeShedResponse(resp, status, l.RetryAfter)
+			return
+		}
+		l.queued.UpdateSince(start)
+
+		handled := time.Now()
+		defer func() {
+			l.release(time.Since(handled))
+		}()
+		next(resp, req)
+	}
+}
+
+// writeShedResponse tells the client of a shed request when to retry it.
+func writeShedResponse(resp http.ResponseWriter, status int, retryAfter time.Duration) {
+	resp.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	writeResponse(resp, status, []byte(`{"message": "Too many requests in progress, please retry later"}`))
+}
+
+// InFlight is the number of requests being handled.
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+// acquire waits for the turn of the request, returning 200 when it's its turn or the status it is shed with.
+func (l *ConcurrencyLimiter) acquire(req *http.Request) int {
+	l.mutex.Lock()
+	if float64(l.inFlight) < math.Floor(l.limit) {
+		l.inFlight++
+		l.mutex.Unlock()
+		return http.StatusOK
+	}
+	if len(l.queue) >= l.QueueSize {
+		l.mutex.Unlock()
+		return http.StatusTooManyRequests
+	}
+	turn := make(chan struct{})
+	l.queue = append(l.queue, turn)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(l.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-turn:
+		return http.StatusOK
+	case <-timer.C:
+	case <-req.Context().Done():
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i, waiting := range l.queue {
+		if waiting == turn {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return http.StatusServiceUnavailable
+		}
+	}
+	// its turn came while giving up
+	return http.StatusOK
+}
+
+func (l *ConcurrencyLimiter) release(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+	if l.Adaptive {
+		l.adapt(float64(latency))
+	}
+	for len(l.queue) > 0 && float64(l.inFlight) < math.Floor(l.limit) {
+		turn := l.queue[0]
+		l.queue = l.queue[1:]
+		l.inFlight++
+		close(turn)
+	}
+}
+
+// adapt moves the limit along the gradient of the latency against its long term average, as in Netflix's gradient limiter:
+// the limit shrinks in proportion as the latency rises, and grows by its square root, the allowance for queueing, while it doesn't.
+func (l *ConcurrencyLimiter) adapt(latency float64) {
+	if !l.latencySeen {
+		l.avgLatency, l.latencySeen = latency, true
+	}
+	l.avgLatency += (latency - l.avgLatency) * adaptiveLatencyWeight
+	if latency <= 0 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.avgLatency/latency))
+	limit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = math.Max(float64(l.MinLimit), math.Min(float64(l.maxLimit), (l.limit+limit)/2))
+	l.current.Update(int64(l.limit))
+}
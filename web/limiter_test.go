@@ -0,0 +1,162 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return NewConcurrencyLimiter(limit, logger.NewUPPLogger("test-logger", "panic"))
+}
+
+// blockingHandler handles the requests once released, signalling when each one starts.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		started <- struct{}{}
+		<-release
+		resp.WriteHeader(http.StatusOK)
+	}
+}
+
+func serveLimited(handler http.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/content/suggest", nil))
+	return w
+}
+
+func TestConcurrencyLimiter_ShedsWhenQueueIsFull(t *testing.T) {
+	expect := assert.New(t)
+	limiter := newTestConcurrencyLimiter(1)
+	limiter.RetryAfter = 1500 * time.Millisecond
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := limiter.Limit(blockingHandler(started, release))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveLimited(handler) }()
+	<-started
+
+	w := serveLimited(handler)
+	expect.Equal(http.StatusTooManyRequests, w.Code)
+	expect.Equal("2", w.Header().Get("Retry-After"))
+	expect.Equal(`{"message": "Too many requests in progress, please retry later"}`, w.Body.String())
+
+	close(release)
+	expect.Equal(http.StatusOK, (<-done).Code)
+	expect.Equal(0, limiter.InFlight())
+}
+
+func TestConcurrencyLimiter_QueuesUntilTurn(t *testing.T) {
+	expect := assert.New(t)
+	limiter := newTestConcurrencyLimiter(1)
+	limiter.QueueSize = 1
+	limiter.QueueTimeout = time.Minute
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := limiter.Limit(blockingHandler(started, release))
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	go func() { done <- serveLimited(handler) }()
+	<-started
+	go func() { done <- serveLimited(handler) }()
+
+	// the second request only starts when the first one completes
+	select {
+	case <-started:
+		t.Fatal("queued request started over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release <- struct{}{}
+	<-started
+	expect.Equal(1, limiter.InFlight())
+	release <- struct{}{}
+
+	expect.Equal(http.StatusOK, (<-done).Code)
+	expect.Equal(http.StatusOK, (<-done).Code)
+	expect.Equal(0, limiter.InFlight())
+}
+
+func TestConcurrencyLimiter_ShedsAfterQueueTimeout(t *testing.T) {
+	expect := assert.New(t)
+	limiter := newTestConcurrencyLimiter(1)
+	limiter.QueueSize = 1
+	limiter.QueueTimeout = 20 * time.Millisecond
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := limiter.Limit(blockingHandler(started, release))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveLimited(handler) }()
+	<-started
+
+	w := serveLimited(handler)
+	expect.Equal(http.StatusServiceUnavailable, w.Code)
+	expect.Equal("1", w.Header().Get("Retry-After"))
+	expect.Empty(limiter.queue)
+
+	close(release)
+	expect.Equal(http.StatusOK, (<-done).Code)
+}
+
+func TestConcurrencyLimiter_Concurrency(t *testing.T) {
+	expect := assert.New(t)
+	limiter := newTestConcurrencyLimiter(3)
+	limiter.QueueSize = 100
+	limiter.QueueTimeout = time.Minute
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	handler := limiter.Limit(func(resp http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expect.Equal(http.StatusOK, serveLimited(handler).Code)
+		}()
+	}
+	wg.Wait()
+	expect.Equal(3, maxInFlight)
+}
+
+func TestConcurrencyLimiter_AdaptsToLatency(t *testing.T) {
+	expect := assert.New(t)
+	limiter := newTestConcurrencyLimiter(100)
+	limiter.Adaptive = true
+	limiter.MinLimit = 8
+
+	for i := 0; i < 20; i++ {
+		limiter.inFlight++
+		limiter.release(10 * time.Millisecond)
+	}
+	expect.Equal(float64(100), limiter.limit)
+
+	// latencies rising well above their average shrink the limit down to its minimum
+	for i := 0; i < 20; i++ {
+		limiter.inFlight++
+		limiter.release(time.Second)
+	}
+	expect.Equal(float64(8), limiter.limit)
+
+	// and it grows back once they are in line with the average again
+	for i := 0; i < 200; i++ {
+		limiter.inFlight++
+		limiter.release(10 * time.Millisecond)
+	}
+	expect.Equal(float64(100), limiter.limit)
+}
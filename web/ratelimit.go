@@ -0,0 +1,275 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	clientIDHeader = "X-Client-Id"
+	// defaultClientID identifies the callers which aren't configured, sharing the default limits.
+	defaultClientID = "default"
+)
+
+// ClientLimit is the rate limit and daily quota of a client. A zero Rate or DailyQuota doesn't limit the client.
+type ClientLimit struct {
+	// ID is matched against the X-Client-Id header of the requests.
+	ID string `json:"id"`
+	// APIKeys are matched against the X-Api-Key header of the requests, identifying the client regardless of its X-Client-Id.
+	APIKeys []string `json:"apiKeys,omitempty"`
+	// Rate is the number of requests per second the bucket of the client is refilled with.
+	Rate float64 `json:"rate"`
+	// Burst is the size of the bucket, i.e. the number of requests the client can make at once. It defaults to the rate.
+	Burst int `json:"burst"`
+	// DailyQuota is the number of requests the client can make per UTC day.
+	DailyQuota int `json:"dailyQuota"`
+}
+
+// ClientLimits are the limits of the configured clients, and the default limits shared by all the other callers.
+type ClientLimits struct {
+	Default *ClientLimit  `json:"default,omitempty"`
+	Clients []ClientLimit `json:"clients"`
+}
+
+// LoadClientLimits reads the client limits from the given JSON file.
+func LoadClientLimits(path string) (ClientLimits, error) {
+	var limits ClientLimits
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return limits, err
+	}
+	if err := json.Unmarshal(content, &limits); err != nil {
+		return limits, err
+	}
+	ids := map[string]bool{defaultClientID: true}
+	for _, client := range limits.Clients {
+		if client.ID == "" || ids[client.ID] {
+			return limits, fmt.Errorf("invalid client id %q, expected a unique id other than %v", client.ID, defaultClientID)
+		}
+		ids[client.ID] = true
+	}
+	return limits, nil
+}
+
+// RateLimiter applies the rate limits and daily quotas of the clients. The clients are identified by their API key or client id,
+// and the requests over their limits are rejected with a 429. The requests shed for lack of capacity, or rejected as invalid,
+// are refunded rather than charged to their client. The usage is tracked by each instance on its own.
+type RateLimiter struct {
+	clients []*clientUsage
+	byID    map[string]*clientUsage
+	dflt    *clientUsage
+	log     *logger.UPPLogger
+	now     func() time.Time
+}
+
+type clientUsage struct {
+	ClientLimit
+	throttled metrics.Counter
+	exhausted metrics.Counter
+
+	mutex     sync.Mutex
+	tokens    float64
+	refilled  time.Time
+	used      int
+	quotaDate time.Time
+}
+
+// rateLimitStatus is the state of the limits of a client after a request, reported in the rate limit headers.
+type rateLimitStatus struct {
+	allowed    bool
+	retryAfter time.Duration
+
+	rated         bool
+	tokens, burst int
+	refill        time.Duration
+	quota, used   int
+	quotaResetsIn time.Duration
+}
+
+func NewRateLimiter(limits ClientLimits, log *logger.UPPLogger) *RateLimiter {
+	r := &RateLimiter{byID: map[string]*clientUsage{}, log: log, now: time.Now}
+	for _, limit := range limits.Clients {
+		usage := newClientUsage(limit)
+		r.clients = append(r.clients, usage)
+		r.byID[limit.ID] = usage
+	}
+	if limits.Default != nil {
+		limit := *limits.Default
+		limit.ID, limit.APIKeys = defaultClientID, nil
+		r.dflt = newClientUsage(limit)
+	}
+	return r
+}
+
+func newClientUsage(limit ClientLimit) *clientUsage {
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	return &clientUsage{
+		ClientLimit: limit,
+		tokens:      float64(limit.Burst),
+		throttled:   metrics.GetOrRegisterCounter("ratelimit."+limit.ID+".throttled", metrics.DefaultRegistry),
+		exhausted:   metrics.GetOrRegisterCounter("ratelimit."+limit.ID+".exhausted", metrics.DefaultRegistry),
+	}
+}
+
+// Limit handles the requests within the limits of their client, and rejects the others.
+func (r *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		client := r.identify(req)
+		if client == nil {
+			next(resp, req)
+			return
+		}
+
+		status := client.take(r.now())
+		status.writeHeaders(resp.Header())
+		if !status.allowed {
+			r.log.WithTransactionID(tidutils.GetTransactionIDFromRequest(req)).Warnf("Rejected request %v %v of client %v over its limits", req.Method, req.URL.Path, client.ID)
+			resp.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(status.retryAfter)))
+			writeResponse(resp, http.StatusTooManyRequests, []byte(`{"message": "Rate limit or daily quota exceeded, please retry later"}`))
+			return
+		}
+		recorder := &statusRecorder{ResponseWriter: resp}
+		next(recorder, req)
+		if !charged(recorder) {
+			client.refund(r.now())
+		}
+	}
+}
+
+// statusRecorder records the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// charged tells whether the request counts towards the limits of its client, i.e. it was neither shed, which the Retry-After
+// of a 429 or 503 tells, nor rejected as invalid.
+func charged(resp *statusRecorder) bool {
+	switch resp.status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return false
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return resp.Header().Get("Retry-After") == ""
+	}
+	return true
+}
+
+// identify returns the usage of the client making the request, or nil when the client isn't limited.
+func (r *RateLimiter) identify(req *http.Request) *clientUsage {
+	if apiKey := req.Header.Get(apiKeyHeader); apiKey != "" {
+		for _, client := range r.clients {
+			for _, key := range client.APIKeys {
+				if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+					return client
+				}
+			}
+		}
+	}
+	if client, ok := r.byID[req.Header.Get(clientIDHeader)]; ok {
+		return client
+	}
+	return r.dflt
+}
+
+// take spends a token and a request of the quota of the client, unless either is exhausted.
+func (c *clientUsage) take(now time.Time) rateLimitStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	status := rateLimitStatus{allowed: true, burst: c.Burst, quota: c.DailyQuota}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(c.quotaDate) {
+		c.quotaDate, c.used = day, 0
+	}
+	status.quotaResetsIn = day.Add(24 * time.Hour).Sub(now)
+	if c.DailyQuota > 0 && c.used >= c.DailyQuota {
+		status.allowed, status.retryAfter = false, status.quotaResetsIn
+		c.exhausted.Inc(1)
+	}
+
+	if c.Rate > 0 {
+		if !c.refilled.IsZero() {
+			c.tokens = math.Min(float64(c.Burst), c.tokens+now.Sub(c.refilled).Seconds()*c.Rate)
+		}
+		c.refilled = now
+		if status.allowed && c.tokens < 1 {
+			status.allowed = false
+			status.retryAfter = time.Duration((1 - c.tokens) / c.Rate * float64(time.Second))
+			c.throttled.Inc(1)
+		}
+	}
+
+	if status.allowed {
+		c.used++
+		if c.Rate > 0 {
+			c.tokens--
+		}
+	}
+	status.used = c.used
+	if c.Rate > 0 {
+		status.rated = true
+		status.tokens = int(c.tokens)
+		status.refill = time.Duration((float64(c.Burst) - c.tokens) / c.Rate * float64(time.Second))
+	}
+	return status
+}
+
+// refund gives back the token and the request of the quota spent by a request which wasn't charged.
+func (c *clientUsage) refund(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// the quota may have been renewed since
+	if c.used > 0 && now.UTC().Truncate(24*time.Hour).Equal(c.quotaDate) {
+		c.used--
+	}
+	if c.Rate > 0 {
+		c.tokens = math.Min(float64(c.Burst), c.tokens+1)
+	}
+}
+
+// writeHeaders reports the token bucket in the RateLimit headers, and the daily quota in the X-Quota headers.
+func (s rateLimitStatus) writeHeaders(header http.Header) {
+	if s.rated {
+		header.Set("RateLimit-Limit", strconv.Itoa(s.burst))
+		header.Set("RateLimit-Remaining", strconv.Itoa(s.tokens))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(s.refill)))
+	}
+	if s.quota > 0 {
+		header.Set("X-Quota-Limit", strconv.Itoa(s.quota))
+		header.Set("X-Quota-Remaining", strconv.Itoa(int(math.Max(0, float64(s.quota-s.used)))))
+		header.Set("X-Quota-Reset", strconv.Itoa(ceilSeconds(s.quotaResetsIn)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
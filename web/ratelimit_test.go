@@ -0,0 +1,193 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(limits ClientLimits, now *time.Time) *RateLimiter {
+	r := NewRateLimiter(limits, logger.NewUPPLogger("test-logger", "panic"))
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func okHandler(resp http.ResponseWriter, req *http.Request) {
+	resp.WriteHeader(http.StatusOK)
+}
+
+func serveRateLimited(handler http.HandlerFunc, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, requestWithHeaders(headers))
+	return w
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestRateLimiter(ClientLimits{Clients: []ClientLimit{{ID: "bulk", Rate: 2, Burst: 3}}}, &now)
+	handler := limiter.Limit(okHandler)
+	bulk := map[string]string{"X-Client-Id": "bulk"}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		w := serveRateLimited(handler, bulk)
+		expect.Equal(http.StatusOK, w.Code)
+		expect.Equal("3", w.Header().Get("RateLimit-Limit"))
+		expect.Equal(strconv.Itoa(remaining), w.Header().Get("RateLimit-Remaining"))
+	}
+
+	w := serveRateLimited(handler, bulk)
+	expect.Equal(http.StatusTooManyRequests, w.Code)
+	expect.Equal("1", w.Header().Get("Retry-After"))
+	expect.Equal("0", w.Header().Get("RateLimit-Remaining"))
+	expect.Equal("2", w.Header().Get("RateLimit-Reset"))
+	expect.Equal(`{"message": "Rate limit or daily quota exceeded, please retry later"}`, w.Body.String())
+	expect.Empty(w.Header().Get("X-Quota-Limit"))
+
+	now = now.Add(500 * time.Millisecond)
+	expect.Equal(http.StatusOK, serveRateLimited(handler, bulk).Code)
+	expect.Equal(http.StatusTooManyRequests, serveRateLimited(handler, bulk).Code)
+
+	// other clients aren't limited without default limits
+	w = serveRateLimited(handler, map[string]string{"X-Client-Id": "editorial"})
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Empty(w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimiter_DailyQuota(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 23, 0, 0, 0, time.UTC)
+	limiter := newTestRateLimiter(ClientLimits{Clients: []ClientLimit{{ID: "bulk", DailyQuota: 2}}}, &now)
+	handler := limiter.Limit(okHandler)
+	bulk := map[string]string{"X-Client-Id": "bulk"}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		w := serveRateLimited(handler, bulk)
+		expect.Equal(http.StatusOK, w.Code)
+		expect.Equal("2", w.Header().Get("X-Quota-Limit"))
+		expect.Equal(strconv.Itoa(remaining), w.Header().Get("X-Quota-Remaining"))
+		expect.Equal("3600", w.Header().Get("X-Quota-Reset"))
+		expect.Empty(w.Header().Get("RateLimit-Limit"))
+	}
+
+	w := serveRateLimited(handler, bulk)
+	expect.Equal(http.StatusTooManyRequests, w.Code)
+	expect.Equal("3600", w.Header().Get("Retry-After"))
+	expect.Equal("0", w.Header().Get("X-Quota-Remaining"))
+
+	// the quota is renewed at midnight UTC
+	now = now.Add(time.Hour)
+	w = serveRateLimited(handler, bulk)
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal("1", w.Header().Get("X-Quota-Remaining"))
+}
+
+func TestRateLimiter_ThrottledRequestsDontUseQuota(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestRateLimiter(ClientLimits{Clients: []ClientLimit{{ID: "bulk", Rate: 1, DailyQuota: 10}}}, &now)
+	handler := limiter.Limit(okHandler)
+	bulk := map[string]string{"X-Client-Id": "bulk"}
+
+	expect.Equal(http.StatusOK, serveRateLimited(handler, bulk).Code)
+	w := serveRateLimited(handler, bulk)
+	expect.Equal(http.StatusTooManyRequests, w.Code)
+	expect.Equal("9", w.Header().Get("X-Quota-Remaining"))
+}
+
+func TestRateLimiter_ShedAndRejectedRequestsAreRefunded(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestRateLimiter(ClientLimits{Clients: []ClientLimit{{ID: "bulk", Rate: 1, DailyQuota: 10}}}, &now)
+	bulk := map[string]string{"X-Client-Id": "bulk"}
+
+	for _, handler := range []http.HandlerFunc{
+		func(resp http.ResponseWriter, req *http.Request) {
+			writeShedResponse(resp, http.StatusTooManyRequests, time.Second)
+		},
+		func(resp http.ResponseWriter, req *http.Request) {
+			writeShedResponse(resp, http.StatusServiceUnavailable, time.Second)
+		},
+		func(resp http.ResponseWriter, req *http.Request) {
+			writeResponse(resp, http.StatusBadRequest, []byte(`{"message": "Payload should be a non-empty JSON object"}`))
+		},
+		func(resp http.ResponseWriter, req *http.Request) {
+			writeResponse(resp, http.StatusRequestEntityTooLarge, []byte(`{"message": "Payload should not be larger than 20 bytes"}`))
+		},
+	} {
+		w := serveRateLimited(limiter.Limit(handler), bulk)
+		expect.NotEqual(http.StatusOK, w.Code)
+	}
+
+	// the token and the quota are still there
+	w := serveRateLimited(limiter.Limit(okHandler), bulk)
+	expect.Equal(http.StatusOK, w.Code)
+	expect.Equal("9", w.Header().Get("X-Quota-Remaining"))
+
+	// failures of the service are charged
+	now = now.Add(time.Second)
+	failing := func(resp http.ResponseWriter, req *http.Request) {
+		writeResponse(resp, http.StatusServiceUnavailable, []byte(`{"message": "aggregating suggestions failed!"}`))
+	}
+	expect.Equal(http.StatusServiceUnavailable, serveRateLimited(limiter.Limit(failing), bulk).Code)
+	w = serveRateLimited(limiter.Limit(okHandler), bulk)
+	expect.Equal(http.StatusTooManyRequests, w.Code)
+	expect.Equal("8", w.Header().Get("X-Quota-Remaining"))
+}
+
+func TestRateLimiter_IdentifiesClients(t *testing.T) {
+	expect := assert.New(t)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestRateLimiter(ClientLimits{
+		Default: &ClientLimit{Rate: 1},
+		Clients: []ClientLimit{{ID: "editorial", APIKeys: []string{"editorial-key"}, Rate: 100}},
+	}, &now)
+
+	expect.Equal("editorial", limiter.identify(requestWithHeaders(map[string]string{"X-Api-Key": "editorial-key"})).ID)
+	expect.Equal("editorial", limiter.identify(requestWithHeaders(map[string]string{"X-Api-Key": "editorial-key", "X-Client-Id": "bulk"})).ID)
+	expect.Equal("editorial", limiter.identify(requestWithHeaders(map[string]string{"X-Client-Id": "editorial"})).ID)
+	expect.Equal("default", limiter.identify(requestWithHeaders(map[string]string{"X-Api-Key": "wrong"})).ID)
+	expect.Equal("default", limiter.identify(requestWithHeaders(nil)).ID)
+
+	// unconfigured callers share the default limits
+	handler := limiter.Limit(okHandler)
+	expect.Equal(http.StatusOK, serveRateLimited(handler, map[string]string{"X-Client-Id": "one"}).Code)
+	expect.Equal(http.StatusTooManyRequests, serveRateLimited(handler, map[string]string{"X-Client-Id": "another"}).Code)
+	expect.Equal(http.StatusOK, serveRateLimited(handler, map[string]string{"X-Client-Id": "editorial"}).Code)
+}
+
+func requestWithHeaders(headers map[string]string) *http.Request {
+	req := httptest.NewRequest("POST", "/content/suggest", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func TestLoadClientLimits(t *testing.T) {
+	expect := assert.New(t)
+	dir, err := ioutil.TempDir("", "client-limits")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "limits.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"default":{"rate":5,"burst":10},"clients":[{"id":"bulk","rate":1,"dailyQuota":1000}]}`), 0600))
+	limits, err := LoadClientLimits(path)
+	require.NoError(t, err)
+	expect.Equal(ClientLimits{Default: &ClientLimit{Rate: 5, Burst: 10}, Clients: []ClientLimit{{ID: "bulk", Rate: 1, DailyQuota: 1000}}}, limits)
+
+	for _, invalid := range []string{`{"clients":[{"rate":1}]}`, `{"clients":[{"id":"default"}]}`, `{"clients":[{"id":"a"},{"id":"a"}]}`, `[]`} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(invalid), 0600))
+		_, err = LoadClientLimits(path)
+		expect.Error(err, invalid)
+	}
+}